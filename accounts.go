@@ -0,0 +1,34 @@
+package ledger
+
+// AccountDeclaration is an account declared with an "account" directive. The
+// indented lines after it may give a note, aliases and a type:
+//
+//	account Expenses:Groceries
+//	    note Food bought for home
+//	    alias groceries
+//	    type Expense
+//
+// Account changes to an alias are read as changes to the declared account.
+// Other sub-directives are ignored.
+type AccountDeclaration struct {
+	Name    string
+	Note    string   `json:",omitempty"`
+	Aliases []string `json:",omitempty"`
+	Type    string   `json:",omitempty"`
+}
+
+// CheckAccounts returns a *ParseError giving the file and line of every
+// account change to an account that is not in accounts, such as
+// Journal.Accounts.
+func CheckAccounts(generalLedger []*Transaction, accounts map[string]*AccountDeclaration) []error {
+	var errs []error
+	for _, trans := range generalLedger {
+		for _, accChange := range trans.AccountChanges {
+			if _, ok := accounts[accChange.Name]; !ok {
+				errs = append(errs, &ParseError{File: accChange.Position.File, Line: accChange.Position.StartLine, Column: 1, Code: CodeUnknownAccount,
+					Text: accChange.Name, Msg: "Unknown account: " + accChange.Name})
+			}
+		}
+	}
+	return errs
+}
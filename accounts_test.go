@@ -0,0 +1,48 @@
+package ledger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAccountDeclarations(t *testing.T) {
+	data := `account Expenses:Groceries
+    note Food bought for home
+    ; not a tag of the next transaction
+    alias groceries
+    type Expense
+account Assets:Checking
+
+2026/01/05 Market
+	groceries  $50.00
+	Assets:Checking
+
+2026/01/06 Market
+	Expenses:Grocieres  $20.00
+	Assets:Checking
+`
+	journal, err := ParseJournal(bytes.NewBufferString(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(journal.Accounts) != 2 {
+		t.Fatalf("expected 2 declared accounts, got %d", len(journal.Accounts))
+	}
+	groceries := journal.Accounts["Expenses:Groceries"]
+	if groceries == nil || groceries.Note != "Food bought for home" || groceries.Type != "Expense" ||
+		len(groceries.Aliases) != 1 || groceries.Aliases[0] != "groceries" {
+		t.Errorf("unexpected declaration %+v", groceries)
+	}
+	if name := journal.Transactions[0].AccountChanges[0].Name; name != "Expenses:Groceries" {
+		t.Errorf("expected the alias to be read as Expenses:Groceries, got %s", name)
+	}
+	if len(journal.Transactions[0].Tags) != 0 {
+		t.Errorf("expected no tags on the transaction, got %v", journal.Transactions[0].Tags)
+	}
+
+	errs := CheckAccounts(journal.Transactions, journal.Accounts)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), ":13:1: Unknown account: Expenses:Grocieres") {
+		t.Errorf("expected an unknown account on line 13, got %v", errs)
+	}
+}
@@ -0,0 +1,79 @@
+package ledger
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// AccountAlias renames accounts while a ledger file is parsed, written as
+// "alias OLD=NEW" in an alias directive or given with WithAlias.
+//
+// A plain alias renames the account OLD and its sub-accounts, so
+// "alias Checking=Assets:Checking" turns "Checking:Joint" into
+// "Assets:Checking:Joint". An alias written "alias /REGEX/=REPLACEMENT"
+// replaces every match of the case insensitive regular expression, and the
+// replacement may refer to groups as \1 or $1.
+type AccountAlias struct {
+	old         string
+	re          *regexp.Regexp
+	replacement string
+}
+
+var aliasGroupRef = regexp.MustCompile(`\\(\d+)`)
+
+// ParseAlias parses the OLD=NEW definition of an alias.
+func ParseAlias(s string) (*AccountAlias, error) {
+	s = strings.Trim(s, whitespace)
+	var old, replacement string
+	if strings.HasPrefix(s, "/") {
+		end := 1
+		for ; end < len(s) && s[end] != '/'; end++ {
+			if s[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(s) {
+			return nil, fmt.Errorf("unterminated regular expression in alias: %s", s)
+		}
+		old, replacement = s[:end+1], strings.TrimLeft(s[end+1:], whitespace)
+		if !strings.HasPrefix(replacement, "=") {
+			return nil, fmt.Errorf("missing = in alias: %s", s)
+		}
+	} else {
+		eqIdx := strings.Index(s, "=")
+		if eqIdx < 0 {
+			return nil, fmt.Errorf("missing = in alias: %s", s)
+		}
+		old, replacement = strings.Trim(s[:eqIdx], whitespace), s[eqIdx:]
+	}
+	replacement = strings.Trim(replacement[1:], whitespace)
+	if len(old) == 0 || len(replacement) == 0 {
+		return nil, fmt.Errorf("missing account name in alias: %s", s)
+	}
+
+	alias := &AccountAlias{old: old, replacement: replacement}
+	if strings.HasPrefix(old, "/") {
+		re, err := queryRegexp(old)
+		if err != nil {
+			return nil, err
+		}
+		alias.re = re
+		alias.replacement = aliasGroupRef.ReplaceAllString(replacement, "$${$1}")
+	}
+	return alias, nil
+}
+
+// Rename returns name as renamed by a.
+func (a *AccountAlias) Rename(name string) string {
+	if a.re != nil {
+		return a.re.ReplaceAllString(name, a.replacement)
+	}
+	if name == a.old {
+		return a.replacement
+	}
+	if strings.HasPrefix(name, a.old+":") {
+		return a.replacement + name[len(a.old):]
+	}
+	return name
+}
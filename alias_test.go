@@ -0,0 +1,63 @@
+package ledger
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAccountAlias(t *testing.T) {
+	cases := []struct {
+		alias, name, expected string
+	}{
+		{"Checking=Assets:Checking", "Checking", "Assets:Checking"},
+		{"Checking=Assets:Checking", "Checking:Joint", "Assets:Checking:Joint"},
+		{"Checking=Assets:Checking", "Checkings", "Checkings"},
+		{"/^expenses:food:(.*)$/=Expenses:Groceries:\\1", "Expenses:Food:Fruit", "Expenses:Groceries:Fruit"},
+		{"/Old/ = New", "Assets:Old:Old", "Assets:New:New"},
+	}
+	for _, tc := range cases {
+		alias, err := ParseAlias(tc.alias)
+		if err != nil {
+			t.Errorf("%s: %s", tc.alias, err)
+			continue
+		}
+		if got := alias.Rename(tc.name); got != tc.expected {
+			t.Errorf("%s: expected %s to be renamed %s, got %s", tc.alias, tc.name, tc.expected, got)
+		}
+	}
+
+	for _, invalid := range []string{"Checking", "=Assets", "/Old=New", "/(/=New"} {
+		if _, err := ParseAlias(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+
+	data := `alias Checking=Assets:Checking
+
+2016/01/05 Market
+	Expenses:Food  $50.00
+	Checking
+
+end aliases
+
+2026/01/05 Market
+	Expenses:Food  $20.00
+	Checking
+`
+	bank, _ := ParseAlias("Expenses:Food=Expenses:Groceries")
+	generalLedger, err := ParseLedger(bytes.NewBufferString(data), WithAlias(bank))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{
+		{"Expenses:Groceries", "Assets:Checking"},
+		{"Expenses:Groceries", "Checking"},
+	}
+	for i, trans := range generalLedger {
+		for j, accChange := range trans.AccountChanges {
+			if accChange.Name != expected[i][j] {
+				t.Errorf("transaction %d: expected %s, got %s", i, expected[i][j], accChange.Name)
+			}
+		}
+	}
+}
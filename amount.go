@@ -0,0 +1,409 @@
+package ledger
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	date "github.com/joyt/godate"
+)
+
+// Characters that can not be part of an unquoted commodity symbol.
+const commodityInvalidChars = " \t\r\n0123456789.,;:?!-+*/^&|=<>{}[]()@\""
+
+// Amount is a quantity of a single commodity. Quantities written without a
+// commodity symbol have an empty Commodity.
+type Amount struct {
+	Quantity  *big.Rat
+	Commodity string
+}
+
+// FloatString returns the amount with prec digits after the decimal point,
+// along with its commodity symbol. Symbols ending in a non-letter such as "$"
+// or "R$" are written before the number, others after it.
+func (a Amount) FloatString(prec int) string {
+	number := a.Quantity.FloatString(prec)
+	if a.Commodity == "" {
+		return number
+	}
+	symbol := quoteCommodity(a.Commodity)
+	lastRune, _ := utf8.DecodeLastRuneInString(a.Commodity)
+	if unicode.IsLetter(lastRune) || symbol != a.Commodity {
+		return number + " " + symbol
+	}
+	if utf8.RuneCountInString(symbol) == 1 {
+		return symbol + number
+	}
+	return symbol + " " + number
+}
+
+// quoteCommodity wraps a commodity symbol in double quotes when it contains
+// characters that would otherwise be read as part of the quantity.
+func quoteCommodity(commodity string) string {
+	if strings.ContainsAny(commodity, commodityInvalidChars) {
+		return `"` + commodity + `"`
+	}
+	return commodity
+}
+
+// Balance is an amount that may hold several commodities at once, keyed by
+// commodity symbol. Quantities written without a commodity are kept under the
+// empty symbol.
+type Balance map[string]*big.Rat
+
+// NewBalance returns a Balance holding quantity of a single commodity.
+func NewBalance(commodity string, quantity *big.Rat) Balance {
+	return Balance{commodity: new(big.Rat).Set(quantity)}
+}
+
+// Add adds other to b and returns b. A nil b is allocated first, so the
+// result should be assigned back, as with append.
+func (b Balance) Add(other Balance) Balance {
+	if b == nil {
+		b = make(Balance, len(other))
+	}
+	for commodity, quantity := range other {
+		if current, ok := b[commodity]; ok {
+			current.Add(current, quantity)
+		} else {
+			b[commodity] = new(big.Rat).Set(quantity)
+		}
+	}
+	return b
+}
+
+// Clone returns a copy of b that shares no quantities with it.
+func (b Balance) Clone() Balance {
+	return make(Balance, len(b)).Add(b)
+}
+
+// Neg returns a new Balance with every quantity of b negated.
+func (b Balance) Neg() Balance {
+	neg := make(Balance, len(b))
+	for commodity, quantity := range b {
+		neg[commodity] = new(big.Rat).Neg(quantity)
+	}
+	return neg
+}
+
+// IsZero reports whether every quantity in b is zero.
+func (b Balance) IsZero() bool {
+	for _, quantity := range b {
+		if quantity.Sign() != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Amounts returns the non-zero quantities of b sorted by commodity.
+func (b Balance) Amounts() []Amount {
+	var amounts []Amount
+	for commodity, quantity := range b {
+		if quantity.Sign() != 0 {
+			amounts = append(amounts, Amount{Quantity: quantity, Commodity: commodity})
+		}
+	}
+	sort.Slice(amounts, func(i, j int) bool {
+		return amounts[i].Commodity < amounts[j].Commodity
+	})
+	return amounts
+}
+
+// parseAmount parses an amount with an optional commodity symbol written
+// before or after the quantity, such as "12.50", "$ -12.50", "-$12.50",
+// "12.50 BRL" or `10 "VWRA"`, or an expression such as "($10 * 3)". The
+// quantity is read with the decimal mark declared for its commodity in
+// formats, see parseNumber. Errors in an expression give their column in s.
+func parseAmount(s string, formats CommodityFormats) (Amount, error) {
+	var amt Amount
+	invalid := fmt.Errorf("invalid amount %q", strings.Trim(s, whitespace))
+	offset := len(s) - len(strings.TrimLeft(s, whitespace))
+	s = strings.Trim(s, whitespace)
+
+	// Numbers with no commodity may also be written as a fraction or with an
+	// exponent, such as "1/3" or "1e3", unless a format is declared for them
+	if strings.ContainsAny(s, "/eE") && formats[""] == nil {
+		if quantity, ok := new(big.Rat).SetString(s); ok {
+			amt.Quantity = quantity
+			return amt, nil
+		}
+	}
+
+	// A sign may come before a prefixed commodity, as in "-$12.50"
+	negate := false
+	if len(s) > 1 && s[0] == '-' && !strings.ContainsRune("0123456789.(", rune(s[1])) {
+		negate = true
+		s = s[1:]
+		offset++
+	}
+
+	commodity, rest := lexCommodity(s)
+	rest = strings.TrimLeft(rest, whitespace)
+	offset += len(s) - len(rest)
+	number, rest := lexNumber(rest)
+	if number == "" {
+		return amt, invalid
+	}
+	if rest = strings.Trim(rest, whitespace); len(rest) > 0 {
+		if commodity != "" {
+			return amt, invalid
+		}
+		commodity, rest = lexCommodity(rest)
+		if commodity == "" || len(strings.Trim(rest, whitespace)) > 0 {
+			return amt, invalid
+		}
+	}
+
+	var quantity *big.Rat
+	if strings.HasPrefix(number, "(") {
+		value, err := evalExpression(number, formats)
+		if err != nil {
+			return amt, shiftColumn(err, offset)
+		}
+		if value.Commodity != "" && commodity != "" && value.Commodity != commodity {
+			return amt, fmt.Errorf("expression in %s is written with %s", value.Commodity, commodity)
+		}
+		if value.Commodity != "" {
+			commodity = value.Commodity
+		}
+		quantity = value.Quantity
+	} else {
+		var ok bool
+		if quantity, ok = parseNumber(number, formats.decimalMark(commodity)); !ok {
+			return amt, invalid
+		}
+	}
+
+	if negate {
+		quantity.Neg(quantity)
+	}
+	amt.Quantity = quantity
+	amt.Commodity = commodity
+	return amt, nil
+}
+
+// shiftColumn moves the column of an expression error by offset, for an
+// expression found offset bytes into the text being parsed.
+func shiftColumn(err error, offset int) error {
+	if exprErr, ok := err.(*exprError); ok {
+		return &exprError{column: exprErr.column + offset, msg: exprErr.msg}
+	}
+	return err
+}
+
+// parsePostingAmount parses an amount optionally followed by the lot it
+// belongs to and by its cost. A lot is written as a per unit price "{$120}" or
+// a total price "{{$600}}", optionally followed by its acquisition date
+// "[2026/01/05]". A cost is either per unit ("10 AAPL @ $150.00") or in total
+// ("-500 USD @@ 2600 BRL"). The returned cost is always a total carrying the
+// sign of the amount, or nil if no cost was given.
+func parsePostingAmount(s string, formats CommodityFormats) (Amount, *Amount, *Lot, error) {
+	var costString string
+	costOffset := -1
+	if atIdx := strings.Index(s, "@"); atIdx >= 0 {
+		s, costString = s[:atIdx], s[atIdx+1:]
+		costOffset = atIdx + 1
+	}
+	var lotString string
+	if lotIdx := strings.IndexAny(s, "{["); lotIdx >= 0 {
+		s, lotString = s[:lotIdx], s[lotIdx:]
+	}
+
+	amt, err := parseAmount(s, formats)
+	if err != nil {
+		return amt, nil, nil, err
+	}
+
+	var lot *Lot
+	if len(lotString) > 0 {
+		if lot, err = parseLot(lotString, amt, formats); err != nil {
+			return amt, nil, nil, shiftColumn(err, len(s))
+		}
+	}
+
+	if costOffset < 0 {
+		return amt, nil, lot, nil
+	}
+	perUnit := true
+	if strings.HasPrefix(costString, "@") {
+		costString = costString[1:]
+		costOffset++
+		perUnit = false
+	}
+	if strings.Trim(costString, whitespace) == "" {
+		// Reported like an expression error, with the column of the "@"
+		at := "@"
+		if !perUnit {
+			at = "@@"
+		}
+		return amt, nil, nil, &exprError{column: costOffset - len(at) + 1, msg: "missing cost after " + at}
+	}
+	cost, err := parseAmount(costString, formats)
+	if err != nil {
+		return amt, nil, nil, shiftColumn(err, costOffset)
+	}
+	if cost.Commodity == amt.Commodity {
+		return amt, nil, nil, fmt.Errorf("cost is in the commodity of the amount, %s", commodityName(amt.Commodity))
+	}
+
+	cost.Quantity.Abs(cost.Quantity)
+	if perUnit {
+		cost.Quantity.Mul(cost.Quantity, new(big.Rat).Abs(amt.Quantity))
+	}
+	if amt.Quantity.Sign() < 0 {
+		cost.Quantity.Neg(cost.Quantity)
+	}
+	return amt, &cost, lot, nil
+}
+
+// parseLot parses the lot annotations that follow amt, such as
+// "{$120} [2026/01/05]" or "{{$600}}".
+func parseLot(s string, amt Amount, formats CommodityFormats) (*Lot, error) {
+	var lot Lot
+	var priceFound bool
+	invalid := fmt.Errorf("invalid lot %q", strings.Trim(s, whitespace))
+	length := len(s)
+	for s = strings.Trim(s, whitespace); len(s) > 0; s = strings.TrimLeft(s, whitespace) {
+		var open, close string
+		switch {
+		case strings.HasPrefix(s, "{{"):
+			open, close = "{{", "}}"
+		case strings.HasPrefix(s, "{"):
+			open, close = "{", "}"
+		case strings.HasPrefix(s, "["):
+			open, close = "[", "]"
+		default:
+			return nil, invalid
+		}
+		end := strings.Index(s, close)
+		if end < 0 {
+			return nil, invalid
+		}
+		annotation := s[len(open):end]
+		annotationOffset := length - len(s) + len(open)
+		s = s[end+len(close):]
+
+		if open == "[" {
+			lotDate, dateErr := date.Parse(strings.Trim(annotation, whitespace))
+			if dateErr != nil || !lot.Date.IsZero() {
+				return nil, invalid
+			}
+			lot.Date = lotDate
+			continue
+		}
+
+		price, err := parseAmount(annotation, formats)
+		if err != nil {
+			return nil, shiftColumn(err, annotationOffset)
+		}
+		if priceFound || price.Commodity == amt.Commodity {
+			return nil, invalid
+		}
+		price.Quantity.Abs(price.Quantity)
+		if open == "{{" {
+			if amt.Quantity.Sign() == 0 {
+				return nil, invalid
+			}
+			price.Quantity.Quo(price.Quantity, new(big.Rat).Abs(amt.Quantity))
+		}
+		lot.Price = price
+		priceFound = true
+	}
+	if !priceFound {
+		return nil, invalid
+	}
+	return &lot, nil
+}
+
+// lexCommodity reads a commodity symbol from the start of s, either quoted or
+// made of characters that can not start a quantity, and returns it along with
+// the remaining text.
+func lexCommodity(s string) (commodity, rest string) {
+	if strings.HasPrefix(s, `"`) {
+		end := strings.Index(s[1:], `"`)
+		if end < 1 {
+			return "", s
+		}
+		return s[1 : end+1], s[end+2:]
+	}
+	end := strings.IndexAny(s, commodityInvalidChars)
+	if end < 0 {
+		end = len(s)
+	}
+	return s[:end], s[end:]
+}
+
+// lexNumber reads the text of a number or of a parenthesized expression from
+// the start of s and returns it along with the remaining text.
+func lexNumber(s string) (number, rest string) {
+	if strings.HasPrefix(s, "(") {
+		end := strings.LastIndex(s, ")")
+		if end < 0 {
+			return "", s
+		}
+		return s[:end+1], s[end+1:]
+	}
+
+	end := 0
+	if end < len(s) && (s[end] == '-' || s[end] == '+') {
+		end++
+	}
+	digits := 0
+	for ; end < len(s) && strings.IndexByte("0123456789.,", s[end]) >= 0; end++ {
+		if s[end] != '.' && s[end] != ',' {
+			digits++
+		}
+	}
+	if digits == 0 {
+		return "", s
+	}
+	return s[:end], s[end:]
+}
+
+// parseNumber parses a decimal number such as "-1,000.50" whose decimal mark
+// is mark, either '.' or ','. The other one may separate thousands. When mark
+// is 0, the last of the two in number is the decimal mark if both are there,
+// and '.' otherwise.
+func parseNumber(number string, mark rune) (*big.Rat, bool) {
+	if mark == 0 {
+		mark = '.'
+		if strings.Contains(number, ".") && strings.LastIndex(number, ",") > strings.LastIndex(number, ".") {
+			mark = ','
+		}
+	}
+	separator := ","
+	if mark == ',' {
+		separator = "."
+	}
+
+	sign := ""
+	if len(number) > 0 && (number[0] == '-' || number[0] == '+') {
+		sign, number = number[:1], number[1:]
+	}
+	intPart, fracPart := number, ""
+	if markIdx := strings.IndexRune(number, mark); markIdx >= 0 {
+		intPart, fracPart = number[:markIdx], "."+number[markIdx+1:]
+		if strings.ContainsAny(fracPart[1:], ".,") {
+			return nil, false
+		}
+	}
+	if groups := strings.Split(intPart, separator); len(groups) > 1 {
+		// Thousands separators must separate groups of three digits
+		if len(groups[0]) == 0 || len(groups[0]) > 3 {
+			return nil, false
+		}
+		for _, group := range groups[1:] {
+			if len(group) != 3 {
+				return nil, false
+			}
+		}
+		intPart = strings.Join(groups, "")
+	}
+
+	quantity, ok := new(big.Rat).SetString(sign + intPart + fracPart)
+	return quantity, ok
+}
@@ -0,0 +1,82 @@
+package ledger
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		input     string
+		ok        bool
+		quantity  *big.Rat
+		commodity string
+	}{
+		{"12.50", true, big.NewRat(25, 2), ""},
+		{"-12.50", true, big.NewRat(-25, 2), ""},
+		{"$12.50", true, big.NewRat(25, 2), "$"},
+		{"$ -12.50", true, big.NewRat(-25, 2), "$"},
+		{"-$12.50", true, big.NewRat(-25, 2), "$"},
+		{"R$ 1000", true, big.NewRat(1000, 1), "R$"},
+		{"12.50 BRL", true, big.NewRat(25, 2), "BRL"},
+		{"10 AAPL", true, big.NewRat(10, 1), "AAPL"},
+		{`10 "VWRA 2"`, true, big.NewRat(10, 1), "VWRA 2"},
+		{"(123 * 3)", true, big.NewRat(369, 1), ""},
+		{"1/3", true, big.NewRat(1, 3), ""},
+		{"-1/3", true, big.NewRat(-1, 3), ""},
+		{"1e3", true, big.NewRat(1000, 1), ""},
+		{"2.5E-1", true, big.NewRat(1, 4), ""},
+		{"10 EUR", true, big.NewRat(10, 1), "EUR"},
+		{"Assets", false, nil, ""},
+		{"Expense:Cars R Us", false, nil, ""},
+		{"Expense:Cars 5", false, nil, ""},
+		{"$ 10 BRL", false, nil, ""},
+		{"10 BRL USD", false, nil, ""},
+	}
+
+	for _, tc := range tests {
+		amt, err := parseAmount(tc.input, nil)
+		if ok := err == nil; ok != tc.ok {
+			t.Errorf("%q: expected ok=%t, got %t", tc.input, tc.ok, ok)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if amt.Quantity.Cmp(tc.quantity) != 0 || amt.Commodity != tc.commodity {
+			t.Errorf("%q: expected %s %q, got %s %q", tc.input, tc.quantity, tc.commodity, amt.Quantity, amt.Commodity)
+		}
+	}
+}
+
+func TestRationalAmountPosting(t *testing.T) {
+	generalLedger, err := ParseLedger(bytes.NewBufferString("2026/01/05 Split\n\tA  1/3\n\tB  1e3\n\tC\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := generalLedger[0].AccountChanges
+	if changes[0].Name != "A" || changes[1].Name != "B" ||
+		changes[2].Balance[""].Cmp(big.NewRat(-3001, 3)) != 0 {
+		t.Errorf("unexpected account changes %v", changes)
+	}
+}
+
+func TestAmountFloatString(t *testing.T) {
+	tests := []struct {
+		amount   Amount
+		expected string
+	}{
+		{Amount{big.NewRat(-25, 2), ""}, "-12.50"},
+		{Amount{big.NewRat(25, 2), "$"}, "$12.50"},
+		{Amount{big.NewRat(25, 2), "R$"}, "R$ 12.50"},
+		{Amount{big.NewRat(10, 1), "AAPL"}, "10.00 AAPL"},
+		{Amount{big.NewRat(10, 1), "VWRA 2"}, `10.00 "VWRA 2"`},
+	}
+
+	for _, tc := range tests {
+		if got := tc.amount.FloatString(2); got != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package ledger
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// ApplyBalanceAssertions walks the transactions in order, keeping the running
+// balance of each account, and checks every balance assertion against it.
+// Balance assignments get their amount set so the account reaches the assigned
+// balance, and their transaction is then balanced.
+//
+// Transactions must be sorted by date; ParseLedger and ParseJournal call this
+// after sorting. Every failed assertion is returned as a *ParseError giving
+// the file and line of the account change.
+func ApplyBalanceAssertions(generalLedger []*Transaction) []error {
+	var errs []error
+	running := make(map[string]Balance)
+
+	for _, trans := range generalLedger {
+		if hasBalanceAssignment(trans) {
+			// Changes to the same account earlier in the transaction count too
+			pending := make(map[string]Balance)
+			for i := range trans.AccountChanges {
+				accChange := &trans.AccountChanges[i]
+				if accChange.Balance == nil && accChange.Assertion != nil {
+					current := new(big.Rat)
+					if quantity, ok := running[accChange.Name][accChange.Assertion.Commodity]; ok {
+						current.Add(current, quantity)
+					}
+					if quantity, ok := pending[accChange.Name][accChange.Assertion.Commodity]; ok {
+						current.Add(current, quantity)
+					}
+					accChange.Balance = NewBalance(accChange.Assertion.Commodity, current.Sub(accChange.Assertion.Quantity, current))
+				}
+				pending[accChange.Name] = pending[accChange.Name].Add(accChange.Balance)
+			}
+			if transErr := balanceTransaction(trans); transErr != nil {
+				first := trans.AccountChanges[0]
+				errs = append(errs, &ParseError{File: first.Position.File, Line: first.Position.StartLine, Column: 1, Code: CodeUnbalanced,
+					Text: first.Name, Msg: "Unable to balance transaction, " + transErr.Error()})
+			}
+		}
+
+		for _, accChange := range trans.AccountChanges {
+			running[accChange.Name] = running[accChange.Name].Add(accChange.Balance)
+			if accChange.Assertion == nil {
+				continue
+			}
+			actual := new(big.Rat)
+			if quantity, ok := running[accChange.Name][accChange.Assertion.Commodity]; ok {
+				actual.Set(quantity)
+			}
+			if actual.Cmp(accChange.Assertion.Quantity) != 0 {
+				prec := differingPrecision(accChange.Assertion.Quantity, actual)
+				errs = append(errs, &ParseError{File: accChange.Position.File, Line: accChange.Position.StartLine, Column: 1, Code: CodeAssertionFailed,
+					Text: accChange.Name, Msg: fmt.Sprintf("Balance assertion failed for %s: expected %s, got %s",
+						accChange.Name, accChange.Assertion.FloatString(prec),
+						Amount{Quantity: actual, Commodity: accChange.Assertion.Commodity}.FloatString(prec))})
+			}
+		}
+	}
+	return errs
+}
+
+// differingPrecision returns the number of decimals, at least 2, needed to
+// tell the different numbers a and b apart.
+func differingPrecision(a, b *big.Rat) int {
+	prec := 2
+	for a.FloatString(prec) == b.FloatString(prec) {
+		prec++
+	}
+	return prec
+}
+
+// hasBalanceAssignment reports whether an account change of trans has its
+// amount left to be computed from a balance assignment.
+func hasBalanceAssignment(trans *Transaction) bool {
+	for _, accChange := range trans.AccountChanges {
+		if accChange.Balance == nil && accChange.Assertion != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package ledger
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestBalanceAssertions(t *testing.T) {
+	data := `2026/01/06 Reconcile
+	Assets:Checking  = 900.00
+	Expenses:Misc
+
+2026/01/01 Opening
+	Assets:Checking  1000.00
+	Equity:Opening
+
+2026/01/05 Groceries
+	Expenses:Food  50.00
+	Assets:Checking  -50.00 = 950.00
+`
+	generalLedger, err := ParseLedger(bytes.NewBufferString(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reconcile := generalLedger[2]
+	if got := reconcile.AccountChanges[0].Balance[""]; got.Cmp(big.NewRat(-50, 1)) != 0 {
+		t.Errorf("expected assigned amount -50, got %s", got.FloatString(2))
+	}
+	if got := reconcile.AccountChanges[1].Balance[""]; got.Cmp(big.NewRat(50, 1)) != 0 {
+		t.Errorf("expected balancing amount 50, got %s", got.FloatString(2))
+	}
+
+	data += `
+2026/01/07 Oops
+	Expenses:Food  10.00
+	Assets:Checking  -10.00 = $800.00
+`
+	_, err = ParseLedger(bytes.NewBufferString(data))
+	if err == nil || !strings.Contains(err.Error(), ":15:1: Balance assertion failed for Assets:Checking") {
+		t.Errorf("expected a failed assertion on line 15, got %v", err)
+	}
+}
+
+func TestBalanceAssertionPrecision(t *testing.T) {
+	data := `2026/01/01 Opening
+	Assets:Checking  1.004
+	Equity:Opening
+
+2026/01/02 Check
+	Assets:Checking  0 = 1.001
+	Equity:Opening
+`
+	_, err := ParseLedger(bytes.NewBufferString(data))
+	if err == nil || !strings.Contains(err.Error(), "expected 1.001, got 1.004") {
+		t.Errorf("expected the balances to differ in the message, got %v", err)
+	}
+}
+
+func TestBalanceAssignmentWithoutAccount(t *testing.T) {
+	data := "2026/01/05 Grocery Store\n\tExpenses:Food  $10\n\t= 100\n\tAssets:Checking\n"
+	generalLedger, err := ParseLedger(bytes.NewBufferString(data))
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 1 || errs[0].Line != 3 || errs[0].Code != CodeInvalidAssertion {
+		t.Errorf("expected an invalid assertion on line 3, got %v", err)
+	}
+	if len(generalLedger) != 1 || len(generalLedger[0].AccountChanges) != 2 {
+		t.Errorf("expected the transaction without the assertion line, got %v", generalLedger)
+	}
+}
+
+func TestBalanceAssertionAccountWithEquals(t *testing.T) {
+	data := "2026/01/05 Transfer\n\tExpenses:A=B  50\n\tAssets:Checking  -50 = -50\n"
+	generalLedger, err := ParseLedger(bytes.NewBufferString(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	accChange := generalLedger[0].AccountChanges[0]
+	if accChange.Name != "Expenses:A=B" || accChange.Assertion != nil || accChange.Balance[""].Cmp(big.NewRat(50, 1)) != 0 {
+		t.Errorf("expected account Expenses:A=B with amount 50 and no assertion, got %+v", accChange)
+	}
+	if generalLedger[0].AccountChanges[1].Assertion == nil {
+		t.Error("expected the assertion of Assets:Checking")
+	}
+}
@@ -0,0 +1,44 @@
+package ledger
+
+import (
+	"math/big"
+)
+
+// AutomatedTransaction is a rule, written as a "= <query>" block, whose
+// account changes are added to every transaction read after it for each of
+// its account changes that matches Query.
+//
+// An account change of the rule whose amount has no commodity multiplies the
+// amount of the matching change, so "(Budget:Food)  -1" mirrors it. One with
+// a commodity is added as it is.
+type AutomatedTransaction struct {
+	Query          *Query
+	AccountChanges []Account
+}
+
+// applyAutomatedTransactions adds the account changes of every rule to trans.
+// Only the account changes trans had to begin with are matched.
+func applyAutomatedTransactions(rules []*AutomatedTransaction, trans *Transaction) {
+	original := trans.AccountChanges
+	for _, rule := range rules {
+		for _, accChange := range original {
+			if !rule.Query.Match(trans, accChange) {
+				continue
+			}
+			for _, ruleChange := range rule.AccountChanges {
+				generated := ruleChange
+				generated.Balance = make(Balance)
+				for commodity, quantity := range ruleChange.Balance {
+					if commodity != "" {
+						generated.Balance.Add(NewBalance(commodity, quantity))
+						continue
+					}
+					for matchedCommodity, matchedQuantity := range accChange.Balance {
+						generated.Balance.Add(NewBalance(matchedCommodity, new(big.Rat).Mul(matchedQuantity, quantity)))
+					}
+				}
+				trans.AccountChanges = append(trans.AccountChanges, generated)
+			}
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package ledger
 
 import (
-	"math/big"
 	"sort"
 	"strings"
 )
@@ -10,9 +9,11 @@ import (
 // all accounts that have any filter as a substring of the account name. Also
 // returns balances for each account level depth as a separate record.
 //
+// Each commodity is kept separate within an account balance.
+//
 // Accounts are sorted by name.
 func GetBalances(generalLedger []*Transaction, filterArr []string) []*Account {
-	balances := make(map[string]*big.Rat)
+	balances := make(map[string]Balance)
 	filters := len(filterArr) > 0
 	for _, trans := range generalLedger {
 		for _, accChange := range trans.AccountChanges {
@@ -31,11 +32,7 @@ func GetBalances(generalLedger []*Transaction, filterArr []string) []*Account {
 				accDepth := len(accHier)
 				for currDepth := accDepth; currDepth > 0; currDepth-- {
 					currAccName := strings.Join(accHier[:currDepth], ":")
-					if ratNum, ok := balances[currAccName]; !ok {
-						balances[currAccName] = new(big.Rat).Set(accChange.Balance)
-					} else {
-						ratNum.Add(ratNum, accChange.Balance)
-					}
+					balances[currAccName] = balances[currAccName].Add(accChange.Balance)
 				}
 			}
 		}
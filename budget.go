@@ -0,0 +1,159 @@
+package ledger
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	date "github.com/joyt/godate"
+)
+
+// PeriodicTransaction is a transaction that recurs every Period, written as a
+// "~ <period>" block such as "~ Monthly". It is used to define budgets and to
+// forecast future transactions.
+//
+// The period may be limited with "from <date>" and "to <date>", and followed
+// by a payee after two spaces: "~ Monthly from 2026/03/01  Loan payment".
+// Start and End are zero when not given; End is not included.
+type PeriodicTransaction struct {
+	Period         Period
+	Start, End     time.Time
+	Payee          string
+	AccountChanges []Account
+}
+
+// parsePeriodicTransaction parses the "~" line of a periodic transaction,
+// without the "~".
+func parsePeriodicTransaction(s string) (*PeriodicTransaction, error) {
+	lineSplit := accountToAmountSpace.Split(strings.Trim(s, whitespace), 2)
+	ptrans := &PeriodicTransaction{}
+	if len(lineSplit) == 2 {
+		ptrans.Payee = lineSplit[1]
+	}
+
+	words := strings.Fields(lineSplit[0])
+	if len(words) == 0 {
+		return nil, fmt.Errorf("missing period")
+	}
+	per, ok := parsePeriod(words[0])
+	if !ok {
+		return nil, fmt.Errorf("unknown period: %s", words[0])
+	}
+	ptrans.Period = per
+
+	for words = words[1:]; len(words) > 0; words = words[2:] {
+		if len(words) < 2 {
+			return nil, fmt.Errorf("missing date after %s", words[0])
+		}
+		boundary, err := date.Parse(words[1])
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse date: %s", words[1])
+		}
+		switch strings.ToLower(words[0]) {
+		case "from", "since":
+			ptrans.Start = boundary
+		case "to", "until":
+			ptrans.End = boundary
+		default:
+			return nil, fmt.Errorf("unexpected %q in period", words[0])
+		}
+	}
+	return ptrans, nil
+}
+
+// BudgetLine compares the actual balance change of an account in a period with
+// its budgeted amount.
+type BudgetLine struct {
+	Account string
+	Actual  Balance
+	Budget  Balance
+}
+
+// Difference returns how much the actual amount exceeds the budget.
+func (l BudgetLine) Difference() Balance {
+	return l.Actual.Clone().Add(l.Budget.Neg())
+}
+
+// PercentUsed returns the actual amount as a percentage of the budget, or
+// false when they are not both in the same single commodity.
+func (l BudgetLine) PercentUsed() (*big.Rat, bool) {
+	budgetAmounts, actualAmounts := l.Budget.Amounts(), l.Actual.Amounts()
+	if len(budgetAmounts) != 1 || len(actualAmounts) > 1 {
+		return nil, false
+	}
+	percent := new(big.Rat)
+	if len(actualAmounts) == 1 {
+		if actualAmounts[0].Commodity != budgetAmounts[0].Commodity {
+			return nil, false
+		}
+		percent.Quo(actualAmounts[0].Quantity, budgetAmounts[0].Quantity)
+		percent.Mul(percent, big.NewRat(100, 1))
+	}
+	return percent, true
+}
+
+// RangeBudget contains the budget lines and the start and end time of the date range
+type RangeBudget struct {
+	Start, End time.Time
+	Lines      []BudgetLine
+}
+
+// BudgetByPeriod compares, for each period, the balance change of every
+// budgeted account with the amounts of the periodic transactions. Budgets
+// defined for another period are scaled to per, so a Monthly budget counts
+// three times in a Quarterly report. A periodic transaction given a Start or
+// End only counts in the periods that overlap its dates.
+func BudgetByPeriod(trans []*Transaction, budget []*PeriodicTransaction, per Period) []*RangeBudget {
+	accNameSet := make(map[string]bool)
+	for _, ptrans := range budget {
+		for _, accChange := range ptrans.AccountChanges {
+			accNameSet[accChange.Name] = true
+		}
+	}
+	accNames := make([]string, 0, len(accNameSet))
+	for accName := range accNameSet {
+		accNames = append(accNames, accName)
+	}
+	sort.Strings(accNames)
+
+	var results []*RangeBudget
+	for _, rb := range BalancesByPeriod(trans, per, RangePartition) {
+		actual := make(map[string]Balance)
+		for _, account := range rb.Balances {
+			actual[account.Name] = account.Balance
+		}
+		budgeted := budgetInRange(budget, per, rb.Start, rb.End)
+		result := &RangeBudget{Start: rb.Start, End: rb.End}
+		for _, accName := range accNames {
+			result.Lines = append(result.Lines, BudgetLine{
+				Account: accName,
+				Actual:  actual[accName].Clone(),
+				Budget:  budgeted[accName].Clone(),
+			})
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// budgetInRange returns the budgeted amount of each account for the period per
+// from start to end, both included, counting only the periodic transactions
+// whose dates overlap it.
+func budgetInRange(budget []*PeriodicTransaction, per Period, start, end time.Time) map[string]Balance {
+	budgeted := make(map[string]Balance)
+	for _, ptrans := range budget {
+		if (!ptrans.Start.IsZero() && ptrans.Start.After(end)) || (!ptrans.End.IsZero() && !ptrans.End.After(start)) {
+			continue
+		}
+		scale := big.NewRat(per.perYear(), ptrans.Period.perYear())
+		for _, accChange := range ptrans.AccountChanges {
+			for commodity, quantity := range accChange.Balance {
+				scaled := new(big.Rat).Quo(quantity, scale)
+				budgeted[accChange.Name] = budgeted[accChange.Name].Add(NewBalance(commodity, scaled))
+			}
+		}
+	}
+	return budgeted
+}
@@ -0,0 +1,114 @@
+package ledger
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestBudgetByPeriod(t *testing.T) {
+	data := `~ Monthly
+	Expenses:Food  $400.00
+	Assets:Checking
+
+2026/01/05 Groceries
+	Expenses:Food  $150.00
+	Assets:Checking
+
+2026/01/20 Groceries
+	Expenses:Food  $150.00
+	Assets:Checking
+
+2026/02/10 Groceries
+	Expenses:Food  $500.00
+	Assets:Checking
+`
+	journal, err := ParseJournal(bytes.NewBufferString(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(journal.Transactions) != 3 {
+		t.Fatalf("expected the periodic transaction to be left out, got %d transactions", len(journal.Transactions))
+	}
+	if len(journal.PeriodicTransactions) != 1 || journal.PeriodicTransactions[0].Period != PeriodMonth {
+		t.Fatalf("expected a monthly periodic transaction, got %v", journal.PeriodicTransactions)
+	}
+
+	budgets := BudgetByPeriod(journal.Transactions, journal.PeriodicTransactions, PeriodMonth)
+	if len(budgets) != 2 {
+		t.Fatalf("expected 2 periods, got %d", len(budgets))
+	}
+	expected := []struct {
+		actual, diff, percent int64
+	}{
+		{300, -100, 75},
+		{500, 100, 125},
+	}
+	for i, rb := range budgets {
+		var food BudgetLine
+		for _, line := range rb.Lines {
+			if line.Account == "Expenses:Food" {
+				food = line
+			}
+		}
+		if got := food.Actual["$"]; got == nil || got.Cmp(big.NewRat(expected[i].actual, 1)) != 0 {
+			t.Errorf("period %d: expected actual $%d, got %v", i, expected[i].actual, got)
+		}
+		if got := food.Difference()["$"]; got.Cmp(big.NewRat(expected[i].diff, 1)) != 0 {
+			t.Errorf("period %d: expected difference $%d, got %s", i, expected[i].diff, got.FloatString(2))
+		}
+		if got, ok := food.PercentUsed(); !ok || got.Cmp(big.NewRat(expected[i].percent, 1)) != 0 {
+			t.Errorf("period %d: expected %d%% used, got %v", i, expected[i].percent, got)
+		}
+	}
+
+	quarterly := BudgetByPeriod(journal.Transactions, journal.PeriodicTransactions, PeriodQuarter)
+	if got := quarterly[0].Lines[1].Budget["$"]; quarterly[0].Lines[1].Account != "Expenses:Food" || got.Cmp(big.NewRat(1200, 1)) != 0 {
+		t.Errorf("expected a quarterly food budget of $1200, got %v", quarterly[0].Lines[1])
+	}
+}
+
+func TestBudgetByPeriodBounded(t *testing.T) {
+	data := `~ Monthly from 2026/02/01 to 2026/03/01
+	Expenses:Rent  $500.00
+	Assets:Checking
+
+2026/01/05 Rent
+	Expenses:Rent  $450.00
+	Assets:Checking
+
+2026/02/05 Rent
+	Expenses:Rent  $500.00
+	Assets:Checking
+
+2026/03/05 Rent
+	Expenses:Rent  $550.00
+	Assets:Checking
+`
+	journal, err := ParseJournal(bytes.NewBufferString(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	budgets := BudgetByPeriod(journal.Transactions, journal.PeriodicTransactions, PeriodMonth)
+	if len(budgets) != 3 {
+		t.Fatalf("expected 3 periods, got %d", len(budgets))
+	}
+	// Only February is within the dates of the periodic transaction
+	expected := []int64{0, 500, 0}
+	for i, rb := range budgets {
+		var rent BudgetLine
+		for _, line := range rb.Lines {
+			if line.Account == "Expenses:Rent" {
+				rent = line
+			}
+		}
+		got := new(big.Rat)
+		if quantity, ok := rent.Budget["$"]; ok {
+			got = quantity
+		}
+		if got.Cmp(big.NewRat(expected[i], 1)) != 0 {
+			t.Errorf("period %d: expected a budget of $%d, got %s", i, expected[i], got.FloatString(2))
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"math/big"
 	"sort"
 	"time"
 	"flag"
@@ -14,9 +13,11 @@ import (
 
 const (
 	transactionDateFormat = "2006/01/02"
-	displayPrecision      = 2
 )
 
+// commodityFormats is how the ledger file declares amounts are written.
+var commodityFormats ledger.CommodityFormats
+
 func main() {
 	var startDate, endDate time.Time
 	startDate = time.Date(1970, 1, 1, 0, 0, 0, 0, time.Local)
@@ -60,11 +61,13 @@ func main() {
 		lreader = ledgerFileReader
 	}
 
-	generalLedger, parseError := ledger.ParseLedger(lreader)
+	journal, parseError := ledger.ParseJournal(lreader)
 	if parseError != nil {
 		fmt.Printf("%s\n", parseError.Error())
 		return
 	}
+	generalLedger := journal.Transactions
+	commodityFormats = journal.Commodities
 
 	timeStartIndex, timeEndIndex := 0, 0
 	for idx := 0; idx < len(generalLedger); idx++ {
@@ -96,28 +99,28 @@ func main() {
 		trans.Date = generalLedger[len(generalLedger)-1].Date
 	}
 
-	balances := make(map[string]*big.Rat)
+	balances := make(map[string]ledger.Balance)
 	for _, trans := range generalLedger {
 		for _, accChange := range trans.AccountChanges {
-			if ratNum, ok := balances[accChange.Name]; !ok {
-				balances[accChange.Name] = new(big.Rat).Set(accChange.Balance)
-			} else {
-				ratNum.Add(ratNum, accChange.Balance)
+			// Virtual account changes are not part of the real balances
+			if accChange.Type != ledger.RealPosting {
+				continue
 			}
+			balances[accChange.Name] = balances[accChange.Name].Add(accChange.Balance)
 		}
 	}
 
-	ratZero := big.NewRat(0, 1)
+	// One opening posting per account and commodity
 	for name, bal := range balances {
-		if bal.Cmp(ratZero) != 0 {
+		for _, amt := range bal.Amounts() {
 			trans.AccountChanges = append(trans.AccountChanges, ledger.Account{
 				Name:    name,
-				Balance: bal,
+				Balance: ledger.NewBalance(amt.Commodity, amt.Quantity),
 			})
 		}
 	}
 
-	sort.Slice(trans.AccountChanges, func(i, j int) bool {
+	sort.SliceStable(trans.AccountChanges, func(i, j int) bool {
 		return trans.AccountChanges[i].Name < trans.AccountChanges[j].Name
 	})
 
@@ -42,23 +42,47 @@ func PrintStats(generalLedger []*ledger.Transaction) {
 
 // PrintBalances prints out account balances formatted to a window set to a width of columns.
 // Only shows accounts with names less than or equal to the given depth.
+// Balances holding several commodities are printed one commodity per line.
 func PrintBalances(accountList []*ledger.Account, printZeroBalances bool, depth, columns int) {
-	overallBalance := new(big.Rat)
+	var overallBalance ledger.Balance
 	for _, account := range accountList {
 		accDepth := len(strings.Split(account.Name, ":"))
 		if accDepth == 1 {
-			overallBalance.Add(overallBalance, account.Balance)
+			overallBalance = overallBalance.Add(account.Balance)
 		}
-		if (printZeroBalances || account.Balance.Sign() != 0) && (depth < 0 || accDepth <= depth) {
-			outBalanceString := account.Balance.FloatString(displayPrecision)
-			spaceCount := columns - utf8.RuneCountInString(account.Name) - utf8.RuneCountInString(outBalanceString)
-			fmt.Printf("%s%s%s\n", account.Name, strings.Repeat(" ", spaceCount), outBalanceString)
+		if (printZeroBalances || !account.Balance.IsZero()) && (depth < 0 || accDepth <= depth) {
+			name := account.Name
+			for _, outBalanceString := range commodityFormats.FormatBalance(account.Balance) {
+				spaceCount := columns - utf8.RuneCountInString(name) - utf8.RuneCountInString(outBalanceString)
+				fmt.Printf("%s%s%s\n", name, strings.Repeat(" ", spaceCount), outBalanceString)
+				name = ""
+			}
 		}
 	}
 	fmt.Println(strings.Repeat("-", columns))
-	outBalanceString := overallBalance.FloatString(displayPrecision)
-	spaceCount := columns - utf8.RuneCountInString(outBalanceString)
-	fmt.Printf("%s%s\n", strings.Repeat(" ", spaceCount), outBalanceString)
+	for _, outBalanceString := range commodityFormats.FormatBalance(overallBalance) {
+		spaceCount := columns - utf8.RuneCountInString(outBalanceString)
+		fmt.Printf("%s%s\n", strings.Repeat(" ", spaceCount), outBalanceString)
+	}
+}
+
+// stateString returns the flag written before a payee or account name for state.
+func stateString(state ledger.TransactionState) string {
+	switch state {
+	case ledger.Cleared:
+		return "* "
+	case ledger.Pending:
+		return "! "
+	}
+	return ""
+}
+
+// codeString returns the code written before a payee, if any.
+func codeString(code string) string {
+	if code == "" {
+		return ""
+	}
+	return "(" + code + ") "
 }
 
 // PrintTransaction prints a transaction formatted to fit in specified column width.
@@ -66,14 +90,34 @@ func PrintTransaction(trans *ledger.Transaction, columns int) {
 	for _, c := range trans.Comments {
 		fmt.Println(c)
 	}
-	fmt.Printf("%s %s\n", trans.Date.Format(transactionDateFormat), trans.Payee)
+	fmt.Printf("%s %s%s%s\n", trans.Date.Format(transactionDateFormat), stateString(trans.State), codeString(trans.Code), trans.Payee)
 	for _, accChange := range trans.AccountChanges {
-		outBalanceString := accChange.Balance.FloatString(displayPrecision)
-		spaceCount := columns - 4 - utf8.RuneCountInString(accChange.Name) - utf8.RuneCountInString(outBalanceString)
-		if spaceCount < 1 {
-			spaceCount = 1
+		accName := accChange.Name
+		switch accChange.Type {
+		case ledger.VirtualPosting:
+			accName = "(" + accName + ")"
+		case ledger.BalancedVirtualPosting:
+			accName = "[" + accName + "]"
+		}
+		accName = stateString(accChange.State) + accName
+		// A posting holding several commodities is written as one posting per commodity
+		for _, outBalanceString := range commodityFormats.FormatBalance(accChange.Balance) {
+			if accChange.Lot != nil {
+				outBalanceString += " {" + commodityFormats.Format(accChange.Lot.Price) + "}"
+				if !accChange.Lot.Date.IsZero() {
+					outBalanceString += " [" + accChange.Lot.Date.Format(transactionDateFormat) + "]"
+				}
+			}
+			if accChange.Cost != nil {
+				cost := ledger.Amount{Quantity: new(big.Rat).Abs(accChange.Cost.Quantity), Commodity: accChange.Cost.Commodity}
+				outBalanceString += " @@ " + commodityFormats.Format(cost)
+			}
+			spaceCount := columns - 4 - utf8.RuneCountInString(accName) - utf8.RuneCountInString(outBalanceString)
+			if spaceCount < 1 {
+				spaceCount = 1
+			}
+			fmt.Printf("    %s%s%s\n", accName, strings.Repeat(" ", spaceCount), outBalanceString)
 		}
-		fmt.Printf("    %s%s%s\n", accChange.Name, strings.Repeat(" ", spaceCount), outBalanceString)
 	}
 	fmt.Println("")
 }
@@ -106,7 +150,7 @@ func PrintRegister(generalLedger []*ledger.Transaction, filterArr []string, colu
 
 	formatString := fmt.Sprintf("%%-10.10s %%-%[1]d.%[1]ds %%-%[2]d.%[2]ds %%10.10s %%10.10s\n", col1width, col2width)
 
-	runningBalance := new(big.Rat)
+	var runningBalance ledger.Balance
 	for _, trans := range generalLedger {
 		for _, accChange := range trans.AccountChanges {
 			inFilter := len(filterArr) == 0
@@ -116,16 +160,31 @@ func PrintRegister(generalLedger []*ledger.Transaction, filterArr []string, colu
 				}
 			}
 			if inFilter {
-				runningBalance.Add(runningBalance, accChange.Balance)
-				outBalanceString := accChange.Balance.FloatString(displayPrecision)
-				outRunningBalanceString := runningBalance.FloatString(displayPrecision)
-				fmt.Printf(formatString,
-					trans.Date.Format(transactionDateFormat),
-					trans.Payee,
-					accChange.Name,
-					outBalanceString,
-					outRunningBalanceString)
+				runningBalance = runningBalance.Add(accChange.Balance)
+				printRegisterLines(formatString, trans, accChange.Name,
+					commodityFormats.FormatBalance(accChange.Balance), commodityFormats.FormatBalance(runningBalance))
 			}
 		}
 	}
 }
+
+// printRegisterLines prints one register entry. Extra commodities of the
+// change or the running total go on following lines with the other columns blank.
+func printRegisterLines(formatString string, trans *ledger.Transaction, accName string, outBalanceStrings, outRunningBalanceStrings []string) {
+	lineCount := len(outBalanceStrings)
+	if len(outRunningBalanceStrings) > lineCount {
+		lineCount = len(outRunningBalanceStrings)
+	}
+	dateString, payee := trans.Date.Format(transactionDateFormat), trans.Payee
+	for i := 0; i < lineCount; i++ {
+		var outBalanceString, outRunningBalanceString string
+		if i < len(outBalanceStrings) {
+			outBalanceString = outBalanceStrings[i]
+		}
+		if i < len(outRunningBalanceStrings) {
+			outRunningBalanceString = outRunningBalanceStrings[i]
+		}
+		fmt.Printf(formatString, dateString, payee, accName, outBalanceString, outRunningBalanceString)
+		dateString, payee, accName = "", "", ""
+	}
+}
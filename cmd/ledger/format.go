@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pedroalbanese/ledger"
+)
+
+// defaultAmountColumn is the column amounts end at in formatted files, the
+// one used by ledger-mode in Emacs.
+const defaultAmountColumn = 52
+
+// formatFiles runs the fmt command on the ledger file filename and returns
+// the exit status: 1 when -check finds a file that is not formatted, 2 on
+// errors.
+func formatFiles(filename string, args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	columns := flags.Int("align", defaultAmountColumn, "Align amounts to end at this column.")
+	check := flags.Bool("check", false, "Print the files that are not formatted instead of formatting them, and exit with status 1 if any.")
+	includes := flags.Bool("include", false, "Also format the files named by include directives.")
+	flags.Parse(args)
+
+	if filename == "-" {
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Println(err)
+			return 2
+		}
+		formatted, err := formatData(data, *columns)
+		if err != nil {
+			fmt.Println(err)
+			return 2
+		}
+		if *check {
+			if !bytes.Equal(data, formatted) {
+				fmt.Println("-")
+				return 1
+			}
+			return 0
+		}
+		os.Stdout.Write(formatted)
+		return 0
+	}
+
+	f := &formatter{check: *check, includes: *includes, columns: *columns, visited: make(map[string]bool)}
+	if err := f.formatFile(filepath.Clean(filename)); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	if f.unformatted {
+		return 1
+	}
+	return 0
+}
+
+// formatter formats a ledger file and, with includes, the files it includes.
+type formatter struct {
+	check       bool
+	includes    bool
+	columns     int
+	visited     map[string]bool
+	unformatted bool
+}
+
+func (f *formatter) formatFile(filename string) error {
+	if f.visited[filename] {
+		return nil
+	}
+	f.visited[filename] = true
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	tree, err := ledger.ParseSyntax(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+
+	if f.includes {
+		for _, node := range tree.Nodes {
+			if node.Kind != ledger.DirectiveNode || node.Keyword != "include" {
+				continue
+			}
+			includedPath, err := ledger.ResolveInclude(filename, node.Value)
+			if err != nil {
+				return fmt.Errorf("%s: %s", filename, err)
+			}
+			includedPaths, err := filepath.Glob(includedPath)
+			if err != nil {
+				return fmt.Errorf("%s: %s", filename, err)
+			}
+			for _, included := range includedPaths {
+				if err := f.formatFile(included); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	tree.Format(f.columns)
+	formatted := []byte(tree.String())
+	if bytes.Equal(data, formatted) {
+		return nil
+	}
+	if f.check {
+		fmt.Println(filename)
+		f.unformatted = true
+		return nil
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, formatted, info.Mode())
+}
+
+// formatData returns the ledger file data formatted.
+func formatData(data []byte, columns int) ([]byte, error) {
+	tree, err := ledger.ParseSyntax(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	tree.Format(columns)
+	return []byte(tree.String()), nil
+}
@@ -13,9 +13,12 @@ import (
 
 const (
 	transactionDateFormat = "2006/01/02"
-	displayPrecision      = 2
 )
 
+// commodityFormats are the commodity formats declared in the ledger file,
+// used to print amounts.
+var commodityFormats ledger.CommodityFormats
+
 func main() {
 	var startDate, endDate time.Time
 	startDate = time.Date(1970, 1, 1, 0, 0, 0, 0, time.Local)
@@ -26,6 +29,14 @@ func main() {
 	var columnWide bool
 	var period string
 	var payeeFilter string
+	var exchangeCommodity, priceDBFileName string
+	var lotMethod string
+	var showCleared, showPending, showUncleared bool
+	var tagFilters stringList
+	var realOnly bool
+	var forecastString string
+	var strict bool
+	var aliasDefinitions stringList
 
 	var ledgerFileName string
 
@@ -34,6 +45,18 @@ func main() {
 	flag.StringVar(&endString, "e", endDate.Format(transactionDateFormat), "End date of transaction processing.")
 	flag.StringVar(&period, "period", "", "Split output into periods (Monthly,Quarterly,SemiYearly,Yearly).")
 	flag.StringVar(&payeeFilter, "payee", "", "Filter output to payees that contain this string.")
+	flag.StringVar(&exchangeCommodity, "X", "", "Convert amounts to this commodity using market prices.")
+	flag.StringVar(&exchangeCommodity, "exchange", "", "Convert amounts to this commodity using market prices (same as -X).")
+	flag.StringVar(&priceDBFileName, "price-db", "", "Ledger file with additional P market price directives.")
+	flag.StringVar(&lotMethod, "lots", "FIFO", "Lot selection method for sales that do not name a lot (FIFO,LIFO).")
+	flag.BoolVar(&showCleared, "cleared", false, "Only include cleared (*) account changes.")
+	flag.BoolVar(&showPending, "pending", false, "Only include pending (!) account changes.")
+	flag.BoolVar(&showUncleared, "uncleared", false, "Only include uncleared account changes.")
+	flag.Var(&tagFilters, "tag", "Filter output to account changes with this tag, given as key or key=value (repeatable).")
+	flag.BoolVar(&realOnly, "real", false, "Leave out virtual account changes.")
+	flag.Var(&aliasDefinitions, "alias", "Rename accounts while parsing, given as old=new or /regex/=replacement (repeatable).")
+	flag.BoolVar(&strict, "strict", false, "Report account changes to accounts not declared with account directives.")
+	flag.StringVar(&forecastString, "forecast", "", "Add transactions forecast from periodic transactions up to this date (YYYY/MM/dd).")
 	flag.BoolVar(&showEmptyAccounts, "empty", false, "Show empty (zero balance) accounts.")
 	flag.IntVar(&transactionDepth, "depth", -1, "Depth of transaction output (balance).")
 	flag.IntVar(&columnWidth, "columns", 79, "Set a column width for output.")
@@ -66,9 +89,17 @@ func main() {
 		fmt.Println(" print: print ledger")
 		fmt.Println(" reg/register: print filtered register")
 		fmt.Println(" stats: ledger summary")
+		fmt.Println(" gains: realized and unrealized capital gains")
+		fmt.Println(" budget: compare account changes with periodic transactions")
+		fmt.Println(" fmt: format the ledger file in place")
 		return
 	}
 
+	if strings.ToLower(args[0]) == "fmt" {
+		// The file is formatted as written, without reading its transactions
+		os.Exit(formatFiles(ledgerFileName, args[1:]))
+	}
+
 	var lreader io.Reader
 
 	if ledgerFileName == "-" {
@@ -82,11 +113,75 @@ func main() {
 		lreader = ledgerFileReader
 	}
 
-	generalLedger, parseError := ledger.ParseLedger(lreader)
+	var parseOptions []ledger.ParseOption
+	for _, aliasDefinition := range aliasDefinitions {
+		alias, err := ledger.ParseAlias(aliasDefinition)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		parseOptions = append(parseOptions, ledger.WithAlias(alias))
+	}
+
+	journal, parseError := ledger.ParseJournal(lreader, parseOptions...)
 	if parseError != nil {
-		fmt.Printf("%s\n", parseError.Error())
+		if errs, ok := parseError.(ledger.ErrorList); ok {
+			for _, err := range errs {
+				fmt.Println(err)
+			}
+		} else {
+			fmt.Println(parseError)
+		}
 		return
 	}
+	generalLedger := journal.Transactions
+	commodityFormats = journal.Commodities
+
+	if strict {
+		accountErrs := ledger.CheckAccounts(generalLedger, journal.Accounts)
+		for _, err := range accountErrs {
+			fmt.Println(err)
+		}
+		if len(accountErrs) > 0 {
+			return
+		}
+	}
+
+	if len(priceDBFileName) > 0 {
+		priceFileReader, err := ledger.NewLedgerReader(priceDBFileName)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		priceJournal, err := ledger.ParseJournal(priceFileReader)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		for _, price := range priceJournal.Prices.Prices() {
+			journal.Prices.Add(price)
+		}
+	}
+
+	if forecastString != "" {
+		forecastDate, err := time.Parse(transactionDateFormat, forecastString)
+		if err != nil {
+			fmt.Println("Unable to parse forecast date string argument.")
+			fmt.Println("Expected format: YYYY/MM/dd")
+			return
+		}
+		// Forecast transactions all come after the journal, so it stays sorted
+		forecast := ledger.Forecast(generalLedger, journal.PeriodicTransactions, forecastDate)
+		generalLedger = append(generalLedger[:len(generalLedger):len(generalLedger)], forecast...)
+
+		endGiven := false
+		flag.Visit(func(f *flag.Flag) {
+			endGiven = endGiven || f.Name == "e"
+		})
+		if !endGiven {
+			parsedEndDate = forecastDate.AddDate(0, 0, 1)
+		}
+	}
 
 	timeStartIndex, timeEndIndex := 0, 0
 	for idx := 0; idx < len(generalLedger); idx++ {
@@ -111,11 +206,62 @@ func main() {
 		}
 	}
 
+	if showCleared || showPending || showUncleared {
+		generalLedger = ledger.FilterAccountChanges(generalLedger, func(t *ledger.Transaction, a ledger.Account) bool {
+			switch t.AccountState(a) {
+			case ledger.Cleared:
+				return showCleared
+			case ledger.Pending:
+				return showPending
+			}
+			return showUncleared
+		})
+	}
+
+	if realOnly {
+		generalLedger = ledger.FilterAccountChanges(generalLedger, func(t *ledger.Transaction, a ledger.Account) bool {
+			return a.Type == ledger.RealPosting
+		})
+	}
+
+	if len(tagFilters) > 0 {
+		hasTags := func(t *ledger.Transaction, a ledger.Account) bool {
+			for _, tagFilter := range tagFilters {
+				tagSplit := strings.SplitN(tagFilter, "=", 2)
+				value, ok := t.AccountTag(a, tagSplit[0])
+				if !ok || (len(tagSplit) == 2 && value != tagSplit[1]) {
+					return false
+				}
+			}
+			return true
+		}
+		if strings.ToLower(args[0]) == "print" {
+			// Print whole transactions so they still balance
+			origLedger := generalLedger
+			generalLedger = make([]*ledger.Transaction, 0)
+			for _, trans := range origLedger {
+				for _, accChange := range trans.AccountChanges {
+					if hasTags(trans, accChange) {
+						generalLedger = append(generalLedger, trans)
+						break
+					}
+				}
+			}
+		} else {
+			generalLedger = ledger.FilterAccountChanges(generalLedger, hasTags)
+		}
+	}
+
 	containsFilterArray := args[1:]
 	switch strings.ToLower(args[0]) {
 	case "balance", "bal":
 		if period == "" {
-			PrintBalances(ledger.GetBalances(generalLedger, containsFilterArray), showEmptyAccounts, transactionDepth, columnWidth)
+			balances := ledger.GetBalances(generalLedger, containsFilterArray)
+			if exchangeCommodity != "" {
+				// Value balances at the last day of the report
+				balances = exchangeBalances(balances, journal.Prices, exchangeCommodity, parsedEndDate.Add(-1*time.Second))
+			}
+			PrintBalances(balances, showEmptyAccounts, transactionDepth, columnWidth)
 		} else {
 			lperiod := ledger.Period(period)
 			rbalances := ledger.BalancesByPeriod(generalLedger, lperiod, ledger.RangePartition)
@@ -126,12 +272,18 @@ func main() {
 				}
 				fmt.Println(rb.Start.Format(transactionDateFormat), "-", rb.End.Format(transactionDateFormat))
 				fmt.Println(strings.Repeat("=", columnWidth))
+				if exchangeCommodity != "" {
+					rb.Balances = exchangeBalances(rb.Balances, journal.Prices, exchangeCommodity, rb.End)
+				}
 				PrintBalances(rb.Balances, showEmptyAccounts, transactionDepth, columnWidth)
 			}
 		}
 	case "print":
 		PrintLedger(generalLedger, containsFilterArray, columnWidth)
 	case "register", "reg":
+		if exchangeCommodity != "" {
+			generalLedger = exchangeTransactions(generalLedger, journal.Prices, exchangeCommodity)
+		}
 		if period == "" {
 			PrintRegister(generalLedger, containsFilterArray, columnWidth)
 		} else {
@@ -148,5 +300,73 @@ func main() {
 		}
 	case "stats":
 		PrintStats(generalLedger)
+	case "gains":
+		// Lots are tracked from the start of the journal, whatever the begin date
+		gainsReport, err := ledger.CapitalGains(journal.Transactions, ledger.LotMethod(strings.ToUpper(lotMethod)), journal.Prices, parsedEndDate.Add(-1*time.Second))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		PrintGains(gainsReport, parsedStartDate, containsFilterArray, columnWidth)
+	case "budget":
+		if len(journal.PeriodicTransactions) == 0 {
+			fmt.Println("No periodic transactions to budget with.")
+			return
+		}
+		// Report in the period of the first budget unless told otherwise
+		lperiod := journal.PeriodicTransactions[0].Period
+		if period != "" {
+			lperiod = ledger.Period(period)
+		}
+		rbudgets := ledger.BudgetByPeriod(generalLedger, journal.PeriodicTransactions, lperiod)
+		for rIdx, rb := range rbudgets {
+			if rIdx > 0 {
+				fmt.Println("")
+				fmt.Println(strings.Repeat("=", columnWidth))
+			}
+			fmt.Println(rb.Start.Format(transactionDateFormat), "-", rb.End.Format(transactionDateFormat))
+			fmt.Println(strings.Repeat("=", columnWidth))
+			PrintBudget(rb.Lines, containsFilterArray, columnWidth)
+		}
 	}
 }
+
+// exchangeBalances returns copies of accounts with their balances converted to
+// commodity using the market prices on date.
+func exchangeBalances(accounts []*ledger.Account, prices *ledger.PriceDB, commodity string, date time.Time) []*ledger.Account {
+	exchanged := make([]*ledger.Account, len(accounts))
+	for i, account := range accounts {
+		exchanged[i] = &ledger.Account{Name: account.Name, Balance: prices.Convert(account.Balance, commodity, date)}
+	}
+	return exchanged
+}
+
+// exchangeTransactions returns copies of transactions with every account
+// change converted to commodity using the market prices on the transaction date.
+func exchangeTransactions(generalLedger []*ledger.Transaction, prices *ledger.PriceDB, commodity string) []*ledger.Transaction {
+	exchanged := make([]*ledger.Transaction, len(generalLedger))
+	for i, trans := range generalLedger {
+		exchangedTrans := *trans
+		exchangedTrans.AccountChanges = make([]ledger.Account, len(trans.AccountChanges))
+		for j, accChange := range trans.AccountChanges {
+			exchangedTrans.AccountChanges[j] = ledger.Account{
+				Name:    accChange.Name,
+				Balance: prices.Convert(accChange.Balance, commodity, trans.Date),
+			}
+		}
+		exchanged[i] = &exchangedTrans
+	}
+	return exchanged
+}
+
+// stringList is a flag that may be given several times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
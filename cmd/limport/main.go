@@ -17,9 +17,12 @@ import (
 
 const (
 	transactionDateFormat = "2006/01/02"
-	displayPrecision      = 2
 )
 
+// commodityFormats holds the commodity directives of the existing ledger, so
+// imported amounts are written the same way.
+var commodityFormats ledger.CommodityFormats
+
 func usage() {
 	fmt.Printf("Usage: %s -f <ledger-file> <account> <csv file>\n", os.Args[0])
 	flag.PrintDefaults()
@@ -68,11 +71,13 @@ func main() {
 		return
 	}
 
-	generalLedger, parseError := ledger.ParseLedger(ledgerFileReader)
+	journal, parseError := ledger.ParseJournal(ledgerFileReader)
 	if parseError != nil {
 		fmt.Printf("%s:%s\n", ledgerFileName, parseError.Error())
 		return
 	}
+	generalLedger := journal.Transactions
+	commodityFormats = journal.Commodities
 
 	var matchingAccount string
 	matchingAccounts := ledger.GetBalances(generalLedger, []string{accountSubstring})
@@ -137,8 +142,9 @@ func main() {
 		return
 	}
 
-	expenseAccount := ledger.Account{Name: "unknown:unknown", Balance: new(big.Rat)}
-	csvAccount := ledger.Account{Name: matchingAccount, Balance: new(big.Rat)}
+	expenseAccount := ledger.Account{Name: "unknown:unknown"}
+	csvAccount := ledger.Account{Name: matchingAccount}
+	amount := new(big.Rat)
 	for _, record := range csvRecords[1:] {
 		inputPayeeWords := strings.Split(record[payeeColumn], " ")
 		csvDate, _ := time.Parse(csvDateFormat, record[dateColumn])
@@ -150,16 +156,17 @@ func main() {
 			}
 
 			// Negate amount if required
-			expenseAccount.Balance.SetString(record[amountColumn])
+			amount.SetString(record[amountColumn])
 			if negateAmount {
-				expenseAccount.Balance.Neg(expenseAccount.Balance)
+				amount.Neg(amount)
 			}
 
 			// Apply scale
-			expenseAccount.Balance = expenseAccount.Balance.Mul(expenseAccount.Balance, ratScale)
+			amount.Mul(amount, ratScale)
+			expenseAccount.Balance = ledger.NewBalance("", amount)
 
 			// Csv amount is the negative of the expense amount
-			csvAccount.Balance.Neg(expenseAccount.Balance)
+			csvAccount.Balance = expenseAccount.Balance.Neg()
 
 			// Create valid transaction for print in ledger format
 			trans := &ledger.Transaction{Date: csvDate, Payee: record[payeeColumn]}
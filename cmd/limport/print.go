@@ -15,12 +15,14 @@ func PrintTransaction(trans *ledger.Transaction, columns int) {
 	}
 	fmt.Printf("%s %s\n", trans.Date.Format(transactionDateFormat), trans.Payee)
 	for _, accChange := range trans.AccountChanges {
-		outBalanceString := accChange.Balance.FloatString(displayPrecision)
-		spaceCount := columns - 4 - utf8.RuneCountInString(accChange.Name) - utf8.RuneCountInString(outBalanceString)
-		if spaceCount < 1 {
-			spaceCount = 1
+		// A posting holding several commodities is written as one posting per commodity
+		for _, outBalanceString := range commodityFormats.FormatBalance(accChange.Balance) {
+			spaceCount := columns - 4 - utf8.RuneCountInString(accChange.Name) - utf8.RuneCountInString(outBalanceString)
+			if spaceCount < 1 {
+				spaceCount = 1
+			}
+			fmt.Printf("    %s%s%s\n", accChange.Name, strings.Repeat(" ", spaceCount), outBalanceString)
 		}
-		fmt.Printf("    %s%s%s\n", accChange.Name, strings.Repeat(" ", spaceCount), outBalanceString)
 	}
 	fmt.Println("")
 }
@@ -1,40 +1,86 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 
 	"github.com/pedroalbanese/ledger"
 )
 
-func usage(name string) {
-	fmt.Printf("Usage: %s <ledger-file>\n", name)
+func usage() {
+	fmt.Printf("Usage: %s [-strict] [-strict-parsing] <ledger-file>\n", os.Args[0])
+	flag.PrintDefaults()
 	os.Exit(1)
 }
 
+// printError prints err, giving the file, line, column and code of parse
+// errors so that editors can jump to them.
+func printError(err error) {
+	if parseErr, ok := err.(*ledger.ParseError); ok {
+		fmt.Printf("%s:%d:%d: %s: %s\n", parseErr.File, parseErr.Line, parseErr.Column, parseErr.Code, parseErr.Msg)
+		return
+	}
+	fmt.Println("Ledger: ", err)
+}
+
 func main() {
-	if len(os.Args) != 2 {
-		usage(os.Args[0])
+	var strict, strictParsing bool
+	flag.BoolVar(&strict, "strict", false, "Report account changes to accounts not declared with account directives.")
+	flag.BoolVar(&strictParsing, "strict-parsing", false, "Report amounts separated from the account name by a single space, and leave out transactions with a problem.")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		usage()
 	}
-	ledgerFileName := os.Args[1]
+	ledgerFileName := flag.Arg(0)
 	ledgerFileReader, err := ledger.NewLedgerReader(ledgerFileName)
 	if err != nil {
 		fmt.Println("Ledger: ", err)
 		return
 	}
+	var parseOptions []ledger.ParseOption
+	if strictParsing {
+		parseOptions = append(parseOptions, ledger.WithStrictParsing())
+	}
 
-	c, e := ledger.ParseLedgerAsync(ledgerFileReader)
+	parser := ledger.NewParser(context.Background(), ledgerFileReader, parseOptions...)
 	errorCount := 0
+	var generalLedger []*ledger.Transaction
 	for {
-		select {
-		case <-c:
-			continue
-		case err := <-e:
-			if err == nil {
-				os.Exit(errorCount)
+		trans, err := parser.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			printError(err)
+			errorCount++
+			if _, ok := err.(*ledger.ParseError); !ok {
+				// The file could not be read any further
+				break
 			}
-			fmt.Println("Ledger: ", err)
+			continue
+		}
+		generalLedger = append(generalLedger, trans)
+	}
+
+	// Balance assertions are checked once every transaction is known
+	sort.SliceStable(generalLedger, func(i, j int) bool {
+		return generalLedger[i].Date.Before(generalLedger[j].Date)
+	})
+	for _, err := range ledger.ApplyBalanceAssertions(generalLedger) {
+		printError(err)
+		errorCount++
+	}
+	if strict {
+		for _, err := range ledger.CheckAccounts(generalLedger, parser.Journal().Accounts) {
+			printError(err)
 			errorCount++
 		}
 	}
+	os.Exit(errorCount)
 }
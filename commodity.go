@@ -0,0 +1,172 @@
+package ledger
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CommodityFormat is how the amounts of a commodity are written, as declared
+// by a "commodity" directive with a sample amount:
+//
+//	commodity R$ 1.000,00
+//	commodity 1,000.00000000 BTC
+//
+// or with a "format" line after the directive:
+//
+//	commodity $
+//	    format $1,000.00
+//
+// ThousandsSeparator is 0 when thousands are not separated.
+type CommodityFormat struct {
+	Symbol             string
+	SymbolFirst        bool
+	SymbolSpace        bool
+	DecimalMark        rune
+	ThousandsSeparator rune
+	Precision          int
+}
+
+// CommodityFormats holds the format of each declared commodity, by symbol.
+type CommodityFormats map[string]*CommodityFormat
+
+// defaultCommodityFormat returns the format used for a commodity with no
+// declared format, the one of Amount.FloatString with two decimals.
+func defaultCommodityFormat(symbol string) *CommodityFormat {
+	format := &CommodityFormat{Symbol: symbol, DecimalMark: '.', Precision: 2}
+	lastRune, _ := utf8.DecodeLastRuneInString(symbol)
+	if symbol != "" && !unicode.IsLetter(lastRune) && quoteCommodity(symbol) == symbol {
+		format.SymbolFirst = true
+		format.SymbolSpace = utf8.RuneCountInString(symbol) > 1
+	} else {
+		format.SymbolSpace = true
+	}
+	return format
+}
+
+// parseCommodityFormat reads the format of a commodity from a sample amount
+// such as "R$ 1.000,00". When the number has both a period and a comma, the
+// last one is the decimal mark. A mark written several times, or a lone comma
+// followed by three digits, separates thousands. Any other lone mark is the
+// decimal mark.
+func parseCommodityFormat(sample string) (*CommodityFormat, error) {
+	s := strings.TrimLeft(strings.Trim(sample, whitespace), "-")
+	format := &CommodityFormat{DecimalMark: '.'}
+
+	symbol, rest := lexCommodity(s)
+	if symbol != "" {
+		format.SymbolFirst = true
+		format.SymbolSpace = len(rest) > 0 && strings.ContainsAny(rest[:1], whitespace)
+		rest = strings.TrimLeft(rest, whitespace)
+	}
+	number, rest := lexNumber(rest)
+	if number == "" {
+		return nil, fmt.Errorf("Unable to parse commodity format: %s", sample)
+	}
+	if !format.SymbolFirst {
+		format.SymbolSpace = len(rest) > 0 && strings.ContainsAny(rest[:1], whitespace)
+		symbol, rest = lexCommodity(strings.TrimLeft(rest, whitespace))
+	}
+	if symbol == "" || len(strings.Trim(rest, whitespace)) > 0 {
+		return nil, fmt.Errorf("Unable to parse commodity format: %s", sample)
+	}
+	format.Symbol = symbol
+
+	lastPeriod, lastComma := strings.LastIndex(number, "."), strings.LastIndex(number, ",")
+	switch {
+	case lastPeriod >= 0 && lastComma >= 0:
+		if lastComma > lastPeriod {
+			format.DecimalMark, format.ThousandsSeparator = ',', '.'
+		} else {
+			format.ThousandsSeparator = ','
+		}
+	case lastComma >= 0:
+		if strings.Count(number, ",") > 1 || len(number)-lastComma-1 == 3 {
+			format.ThousandsSeparator = ','
+		} else {
+			format.DecimalMark = ','
+		}
+	case strings.Count(number, ".") > 1:
+		format.DecimalMark, format.ThousandsSeparator = ',', '.'
+	}
+	if markIdx := strings.LastIndex(number, string(format.DecimalMark)); markIdx >= 0 {
+		format.Precision = len(number) - markIdx - 1
+	}
+	if _, ok := parseNumber(number, format.DecimalMark); !ok {
+		return nil, fmt.Errorf("Unable to parse commodity format: %s", sample)
+	}
+	return format, nil
+}
+
+// Format writes quantity in format, rounded to its precision.
+func (format *CommodityFormat) Format(quantity *big.Rat) string {
+	number := quantity.FloatString(format.Precision)
+	sign := ""
+	if strings.HasPrefix(number, "-") {
+		sign, number = "-", number[1:]
+	}
+	intPart, fracPart := number, ""
+	if dotIdx := strings.Index(number, "."); dotIdx >= 0 {
+		intPart, fracPart = number[:dotIdx], number[dotIdx+1:]
+	}
+	if format.ThousandsSeparator != 0 {
+		var grouped strings.Builder
+		for i, digit := range intPart {
+			if i > 0 && (len(intPart)-i)%3 == 0 {
+				grouped.WriteRune(format.ThousandsSeparator)
+			}
+			grouped.WriteRune(digit)
+		}
+		intPart = grouped.String()
+	}
+	number = sign + intPart
+	if len(fracPart) > 0 {
+		number += string(format.DecimalMark) + fracPart
+	}
+
+	if format.Symbol == "" {
+		return number
+	}
+	space := ""
+	if format.SymbolSpace {
+		space = " "
+	}
+	if format.SymbolFirst {
+		return quoteCommodity(format.Symbol) + space + number
+	}
+	return number + space + quoteCommodity(format.Symbol)
+}
+
+// Format writes amt in the format declared for its commodity, or as
+// Amount.FloatString with two decimals when it has none.
+func (formats CommodityFormats) Format(amt Amount) string {
+	if format, ok := formats[amt.Commodity]; ok {
+		return format.Format(amt.Quantity)
+	}
+	return amt.FloatString(2)
+}
+
+// FormatBalance writes each commodity of bal with Format, sorted by
+// commodity, or a single zero when bal is empty.
+func (formats CommodityFormats) FormatBalance(bal Balance) []string {
+	amounts := bal.Amounts()
+	if len(amounts) == 0 {
+		return []string{formats.Format(Amount{Quantity: new(big.Rat)})}
+	}
+	strs := make([]string, len(amounts))
+	for i, amt := range amounts {
+		strs[i] = formats.Format(amt)
+	}
+	return strs
+}
+
+// decimalMark returns the decimal mark declared for commodity, or 0 when it
+// has no declared format.
+func (formats CommodityFormats) decimalMark(commodity string) rune {
+	if format, ok := formats[commodity]; ok {
+		return format.DecimalMark
+	}
+	return 0
+}
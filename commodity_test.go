@@ -0,0 +1,103 @@
+package ledger
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestCommodityFormat(t *testing.T) {
+	cases := []struct {
+		sample   string
+		quantity *big.Rat
+		expected string
+	}{
+		{"R$ 1.000,00", big.NewRat(-15000050, 100), "R$ -150.000,50"},
+		{"1,000.00000000 BTC", big.NewRat(1, 3), "0.33333333 BTC"},
+		{"1,000 JPY", big.NewRat(1234567, 1), "1,234,567 JPY"},
+		{"$1,000.00", big.NewRat(999, 1), "$999.00"},
+		{"€1.000.000", big.NewRat(1234, 1), "€1.234"},
+		{"10 \"VWRA\"", big.NewRat(25, 2), "13 VWRA"},
+		{"1000,5 EUR", big.NewRat(5, 2), "2,5 EUR"},
+	}
+	for _, tc := range cases {
+		format, err := parseCommodityFormat(tc.sample)
+		if err != nil {
+			t.Errorf("%s: %s", tc.sample, err)
+			continue
+		}
+		if got := format.Format(tc.quantity); got != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.sample, tc.expected, got)
+		}
+	}
+
+	for _, invalid := range []string{"1,000.00", "$", "$ 1.000,00 BRL"} {
+		if _, err := parseCommodityFormat(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	cases := []struct {
+		number   string
+		mark     rune
+		expected *big.Rat
+	}{
+		{"1,000.50", 0, big.NewRat(200100, 200)},
+		{"1.000,50", 0, big.NewRat(200100, 200)},
+		{"1.000,50", ',', big.NewRat(200100, 200)},
+		{"-12,5", ',', big.NewRat(-25, 2)},
+		{"1,234,567", '.', big.NewRat(1234567, 1)},
+		{"1,5", '.', nil},
+		{"1.5", ',', nil},
+		{"1,0000", 0, nil},
+		{"1.2.3", '.', nil},
+	}
+	for _, tc := range cases {
+		got, ok := parseNumber(tc.number, tc.mark)
+		if tc.expected == nil {
+			if ok {
+				t.Errorf("%s: expected an error, got %s", tc.number, got.FloatString(2))
+			}
+			continue
+		}
+		if !ok || got.Cmp(tc.expected) != 0 {
+			t.Errorf("%s: expected %s, got %v", tc.number, tc.expected.FloatString(2), got)
+		}
+	}
+}
+
+func TestCommodityDirectives(t *testing.T) {
+	data := `commodity R$ 1.000,00
+commodity BTC
+    format 1,000.00000000 BTC
+
+2026/01/05 Buy
+	Assets:BTC  0.5 BTC @@ R$ 150.000,50
+	Assets:Checking
+`
+	journal, err := ParseJournal(bytes.NewBufferString(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format := journal.Commodities["BTC"]; format == nil || format.Precision != 8 || format.SymbolFirst {
+		t.Errorf("unexpected BTC format %+v", format)
+	}
+	checking := journal.Transactions[0].AccountChanges[1]
+	if got := journal.Commodities.Format(Amount{Quantity: checking.Balance["R$"], Commodity: "R$"}); got != "R$ -150.000,50" {
+		t.Errorf("expected R$ -150.000,50, got %s", got)
+	}
+	if got := journal.Commodities.Format(Amount{Quantity: big.NewRat(1, 2), Commodity: "USD"}); got != "0.50 USD" {
+		t.Errorf("expected an undeclared commodity with two decimals, got %s", got)
+	}
+
+	bal := NewBalance("R$", big.NewRat(1000, 1)).Add(NewBalance("BTC", big.NewRat(1, 4)))
+	if got := strings.Join(journal.Commodities.FormatBalance(bal), ", "); got != "0.25000000 BTC, R$ 1.000,00" {
+		t.Errorf("expected each commodity of the balance, got %s", got)
+	}
+	if got := journal.Commodities.FormatBalance(nil); len(got) != 1 || got[0] != "0.00" {
+		t.Errorf("expected a single zero for an empty balance, got %v", got)
+	}
+}
@@ -1,6 +1,9 @@
 package ledger
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // TransactionsInDateRange returns a new array of transactions that are in the date range
 // specified by start and end. The returned list contains transactions on the same day as start
@@ -34,6 +37,37 @@ const (
 	PeriodYear     Period = "Yearly"
 )
 
+// parsePeriod returns the Period named by s, ignoring case.
+func parsePeriod(s string) (Period, bool) {
+	for _, per := range []Period{PeriodDay, PeriodWeek, Period2Week, PeriodMonth, Period2Month, PeriodQuarter, PeriodSemiYear, PeriodYear} {
+		if strings.EqualFold(string(per), s) {
+			return per, true
+		}
+	}
+	return "", false
+}
+
+// perYear returns how many times a period occurs in a year.
+func (per Period) perYear() int64 {
+	switch per {
+	case PeriodDay:
+		return 365
+	case PeriodWeek:
+		return 52
+	case Period2Week:
+		return 26
+	case PeriodMonth:
+		return 12
+	case Period2Month:
+		return 6
+	case PeriodQuarter:
+		return 4
+	case PeriodSemiYear:
+		return 2
+	}
+	return 1
+}
+
 func getDateBoundaries(per Period, start, end time.Time) []time.Time {
 	var incDays, incMonth, incYear int
 	var periodStart time.Time
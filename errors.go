@@ -0,0 +1,73 @@
+package ledger
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrorCode tells what kind of problem a ParseError is about.
+type ErrorCode string
+
+// Error codes of the problems found while parsing a ledger file
+const (
+	CodeInvalidPayee     ErrorCode = "invalid-payee"     // payee line with no payee
+	CodeInvalidDate      ErrorCode = "invalid-date"      // date that can not be parsed
+	CodeInvalidAmount    ErrorCode = "invalid-amount"    // amount or amount expression that can not be parsed
+	CodeMissingAmount    ErrorCode = "missing-amount"    // account change that must have an amount
+	CodeMissingAccount   ErrorCode = "missing-account"   // account change with no account name
+	CodeUnbalanced       ErrorCode = "unbalanced"        // transaction that does not balance
+	CodeInvalidAssertion ErrorCode = "invalid-assertion" // balance assertion that can not be parsed
+	CodeAssertionFailed  ErrorCode = "assertion-failed"  // balance assertion that does not hold
+	CodeInvalidDirective ErrorCode = "invalid-directive" // directive that can not be parsed
+	CodeUnknownAccount   ErrorCode = "unknown-account"   // account that was not declared
+)
+
+// ParseError is a problem found in a ledger file. Line and Column count from
+// 1, and Text is the part of the line the problem is about.
+type ParseError struct {
+	File   string
+	Line   int
+	Column int
+	Code   ErrorCode
+	Text   string
+	Msg    string
+}
+
+func (e *ParseError) Error() string {
+	if e.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// ErrorList is the list of every problem found in a ledger file, in the order
+// they were found.
+type ErrorList []*ParseError
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Err returns l as an error, or nil if l is empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// newParseError returns a ParseError about text in line, with the column
+// where text starts, or the first column when it is not in line.
+func newParseError(file string, lineNum int, line string, code ErrorCode, text, msg string) *ParseError {
+	column := 1
+	if idx := strings.Index(line, text); idx >= 0 && len(text) > 0 {
+		column = idx + 1
+	}
+	return &ParseError{File: file, Line: lineNum, Column: column, Code: code, Text: text, Msg: msg}
+}
@@ -0,0 +1,78 @@
+package ledger
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestErrorList(t *testing.T) {
+	data := `2026/01/05 Grocery Store
+	Expenses:Food  ($10 / 0)
+	Assets:Checking  $-10
+
+2026/13/05 Pay day
+	Income:Salary  $-100
+	Assets:Checking
+
+2026/01/07 Bank
+	Assets:Checking  $50 = $20
+	Income:Interest
+`
+	_, err := ParseLedger(bytes.NewBufferString(data))
+	errs, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("expected an ErrorList, got %v", err)
+	}
+
+	expected := []struct {
+		line, column int
+		code         ErrorCode
+	}{
+		{2, 22, CodeInvalidAmount},
+		{5, 1, CodeInvalidDate},
+		{10, 1, CodeAssertionFailed},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, exp := range expected {
+		if errs[i].Line != exp.line || errs[i].Column != exp.column || errs[i].Code != exp.code {
+			t.Errorf("error %d: expected line %d, column %d, %s, got %d, %d, %s (%v)",
+				i, exp.line, exp.column, exp.code, errs[i].Line, errs[i].Column, errs[i].Code, errs[i])
+		}
+	}
+}
+
+func TestParseErrorString(t *testing.T) {
+	err := newParseError("ledger.dat", 3, "\tAssets:Cash  $1.2.3", CodeInvalidAmount, "$1.2.3", "Unable to parse amount")
+	if err.Error() != "ledger.dat:3:15: Unable to parse amount" {
+		t.Errorf("unexpected error string: %s", err)
+	}
+}
+
+func TestStateFlagOnlyPosting(t *testing.T) {
+	for _, flag := range []string{"*", "!"} {
+		data := "2026/01/05 Grocery Store\n\tExpenses:Food  $10\n\t" + flag + "\n\tAssets:Checking\n"
+		generalLedger, err := ParseLedger(bytes.NewBufferString(data))
+		errs, ok := err.(ErrorList)
+		if !ok || len(errs) != 1 || errs[0].Line != 3 || errs[0].Column != 2 || errs[0].Code != CodeMissingAccount {
+			t.Errorf("%s: expected a missing account on line 3, got %v", flag, err)
+		}
+		if len(generalLedger) != 1 || len(generalLedger[0].AccountChanges) != 2 {
+			t.Errorf("%s: expected the transaction without the flag line, got %v", flag, generalLedger)
+		}
+	}
+}
+
+func TestEmptyCost(t *testing.T) {
+	for _, at := range []string{"@", "@@"} {
+		data := "2026/01/05 Exchange\n\tAssets:USD  1 USD " + at + "\n\tAssets:BRL  -5 BRL\n"
+		for _, opts := range [][]ParseOption{nil, {WithStrictParsing()}} {
+			_, err := ParseLedger(bytes.NewBufferString(data), opts...)
+			errs, ok := err.(ErrorList)
+			if !ok || len(errs) != 1 || errs[0].Line != 2 || errs[0].Column != 20 || errs[0].Code != CodeInvalidAmount {
+				t.Errorf("%s: expected an invalid amount at line 2, column 20, got %v", at, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package ledger
+
+import (
+	"fmt"
+	"strings"
+)
+
+// exprError is an error in an amount expression, or in the cost of an amount,
+// found at column (counting from 1) of the amount text.
+type exprError struct {
+	column int
+	msg    string
+}
+
+func (e *exprError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.column, e.msg)
+}
+
+// evalExpression computes the value of a parenthesized amount expression such
+// as "($10.00 * 3)" or "(1000 BRL / 3 - 2)" exactly. Expressions may use
+// + - * /, unary minus and parentheses. Operands may carry a commodity, but
+// only amounts of the same commodity can be added, a number added to an
+// amount takes its commodity, an amount can only be multiplied by a number,
+// and dividing two amounts of the same commodity gives a number.
+func evalExpression(s string, formats CommodityFormats) (Amount, error) {
+	p := &exprParser{s: s, formats: formats}
+	p.skipSpace()
+	if !strings.HasPrefix(s[p.pos:], "(") {
+		return Amount{}, p.errorf("expected (")
+	}
+	value, err := p.parsePrimary()
+	if err != nil {
+		return Amount{}, err
+	}
+	p.skipSpace()
+	if p.pos < len(s) {
+		return Amount{}, p.errorf("unexpected %q after expression", s[p.pos:])
+	}
+	return value, nil
+}
+
+type exprParser struct {
+	s       string
+	pos     int
+	formats CommodityFormats
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	return &exprError{column: p.pos + 1, msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.s) && strings.IndexByte(whitespace, p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// peek returns the next character after any whitespace, or 0 at the end.
+func (p *exprParser) peek() byte {
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *exprParser) parseSum() (Amount, error) {
+	value, err := p.parseProduct()
+	if err != nil {
+		return value, err
+	}
+	for op := p.peek(); op == '+' || op == '-'; op = p.peek() {
+		opPos := p.pos
+		p.pos++
+		right, err := p.parseProduct()
+		if err != nil {
+			return value, err
+		}
+		// A number takes the commodity of the amount it is added to
+		if value.Commodity == "" {
+			value.Commodity = right.Commodity
+		} else if right.Commodity != "" && value.Commodity != right.Commodity {
+			p.pos = opPos
+			return value, p.errorf("cannot add %s and %s", commodityName(value.Commodity), commodityName(right.Commodity))
+		}
+		if op == '+' {
+			value.Quantity.Add(value.Quantity, right.Quantity)
+		} else {
+			value.Quantity.Sub(value.Quantity, right.Quantity)
+		}
+	}
+	return value, nil
+}
+
+func (p *exprParser) parseProduct() (Amount, error) {
+	value, err := p.parseUnary()
+	if err != nil {
+		return value, err
+	}
+	for op := p.peek(); op == '*' || op == '/'; op = p.peek() {
+		opPos := p.pos
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return value, err
+		}
+		if op == '*' {
+			if value.Commodity != "" && right.Commodity != "" {
+				p.pos = opPos
+				return value, p.errorf("cannot multiply %s by %s", value.Commodity, right.Commodity)
+			}
+			if value.Commodity == "" {
+				value.Commodity = right.Commodity
+			}
+			value.Quantity.Mul(value.Quantity, right.Quantity)
+			continue
+		}
+		if right.Quantity.Sign() == 0 {
+			p.pos = opPos
+			return value, p.errorf("division by zero")
+		}
+		switch right.Commodity {
+		case "":
+		case value.Commodity:
+			value.Commodity = ""
+		default:
+			p.pos = opPos
+			return value, p.errorf("cannot divide %s by %s", commodityName(value.Commodity), right.Commodity)
+		}
+		value.Quantity.Quo(value.Quantity, right.Quantity)
+	}
+	return value, nil
+}
+
+func (p *exprParser) parseUnary() (Amount, error) {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		value, err := p.parseUnary()
+		if err == nil {
+			value.Quantity.Neg(value.Quantity)
+		}
+		return value, err
+	case '+':
+		p.pos++
+		return p.parseUnary()
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary reads a parenthesized expression or an operand, which is a
+// number with an optional commodity before or after it.
+func (p *exprParser) parsePrimary() (Amount, error) {
+	var value Amount
+	switch c := p.peek(); {
+	case c == 0:
+		return value, p.errorf("unexpected end of expression")
+	case c == '(':
+		p.pos++
+		value, err := p.parseSum()
+		if err != nil {
+			return value, err
+		}
+		if p.peek() != ')' {
+			if p.pos == len(p.s) {
+				return value, p.errorf("missing )")
+			}
+			return value, p.errorf("unexpected %q", p.s[p.pos:p.pos+1])
+		}
+		p.pos++
+		return value, nil
+	case strings.IndexByte(")*/+", c) >= 0:
+		return value, p.errorf("unexpected %q", string(c))
+	}
+
+	commodity, rest := lexCommodity(p.s[p.pos:])
+	p.pos = len(p.s) - len(strings.TrimLeft(rest, whitespace))
+	numberPos := p.pos
+	number, rest := lexNumber(p.s[p.pos:])
+	if number == "" || strings.HasPrefix(number, "(") {
+		return value, p.errorf("expected a number")
+	}
+	p.pos = len(p.s) - len(rest)
+	if commodity == "" {
+		// The commodity may also follow the number
+		afterSpace := strings.TrimLeft(rest, whitespace)
+		if suffix, suffixRest := lexCommodity(afterSpace); suffix != "" {
+			commodity = suffix
+			p.pos = len(p.s) - len(suffixRest)
+		}
+	}
+	quantity, ok := parseNumber(number, p.formats.decimalMark(commodity))
+	if !ok {
+		p.pos = numberPos
+		return value, p.errorf("invalid number %q", number)
+	}
+	value.Quantity = quantity
+	value.Commodity = commodity
+	return value, nil
+}
+
+// commodityName names a commodity in error messages.
+func commodityName(commodity string) string {
+	if commodity == "" {
+		return "a number"
+	}
+	return commodity
+}
@@ -0,0 +1,69 @@
+package ledger
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestEvalExpression(t *testing.T) {
+	cases := []struct {
+		input     string
+		quantity  *big.Rat
+		commodity string
+	}{
+		{"(10/3)", big.NewRat(10, 3), ""},
+		{"(0.1 + 0.2)", big.NewRat(3, 10), ""},
+		{"(123 * 3)", big.NewRat(369, 1), ""},
+		{"(-(2 + 3) * -2)", big.NewRat(10, 1), ""},
+		{"(1 - 2 - 3)", big.NewRat(-4, 1), ""},
+		{"(2 + 3 * 4)", big.NewRat(14, 1), ""},
+		{"($10.00 * 3)", big.NewRat(30, 1), "$"},
+		{"(3 * 100 BRL / 4)", big.NewRat(75, 1), "BRL"},
+		{"($10 + $2.50)", big.NewRat(25, 2), "$"},
+		{"($1 + 2)", big.NewRat(3, 1), "$"},
+		{"(10 - 2.50 BRL)", big.NewRat(15, 2), "BRL"},
+		{"($10 / $4)", big.NewRat(5, 2), ""},
+		{"($-10)", big.NewRat(-10, 1), "$"},
+	}
+	for _, tc := range cases {
+		amt, err := evalExpression(tc.input, nil)
+		if err != nil {
+			t.Errorf("%s: %s", tc.input, err)
+			continue
+		}
+		if amt.Quantity.Cmp(tc.quantity) != 0 || amt.Commodity != tc.commodity {
+			t.Errorf("%s: expected %s %q, got %s %q", tc.input, tc.quantity, tc.commodity, amt.Quantity, amt.Commodity)
+		}
+	}
+
+	errorCases := []struct {
+		input, expected string
+	}{
+		{"(10 / 0)", "column 5: division by zero"},
+		{"(10 + 3", "column 8: missing )"},
+		{"($10 + 5 BRL)", "column 6: cannot add $ and BRL"},
+		{"($10 * $2)", "column 6: cannot multiply $ by $"},
+		{"(10 * )", "column 7: unexpected \")\""},
+		{"(10) 5", "column 6: unexpected \"5\" after expression"},
+		{"(1,00 + 1)", "column 2: invalid number \"1,00\""},
+	}
+	for _, tc := range errorCases {
+		_, err := evalExpression(tc.input, nil)
+		if err == nil || err.Error() != tc.expected {
+			t.Errorf("%s: expected error %q, got %v", tc.input, tc.expected, err)
+		}
+	}
+}
+
+func TestExpressionErrorColumn(t *testing.T) {
+	data := `2026/01/05 Split
+	Expenses:Food  ($30 / 0)
+	Assets:Checking
+`
+	_, err := ParseLedger(bytes.NewBufferString(data))
+	if err == nil || !strings.Contains(err.Error(), ":2:22: Unable to parse amount: division by zero") {
+		t.Errorf("expected a division by zero at column 22, got %v", err)
+	}
+}
@@ -0,0 +1,24 @@
+package ledger
+
+// FilterAccountChanges returns copies of the transactions holding only the
+// account changes for which keep returns true. Transactions left with no
+// account changes are dropped.
+//
+// The result is meant for reporting: filtered transactions need not balance.
+func FilterAccountChanges(generalLedger []*Transaction, keep func(t *Transaction, a Account) bool) []*Transaction {
+	var filtered []*Transaction
+	for _, trans := range generalLedger {
+		var accChanges []Account
+		for _, accChange := range trans.AccountChanges {
+			if keep(trans, accChange) {
+				accChanges = append(accChanges, accChange)
+			}
+		}
+		if len(accChanges) > 0 {
+			filteredTrans := *trans
+			filteredTrans.AccountChanges = accChanges
+			filtered = append(filtered, &filteredTrans)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,56 @@
+package ledger
+
+import (
+	"sort"
+	"time"
+)
+
+// Forecast generates the transactions that periodic transactions would create
+// after the last transaction of trans, up to and including end. Each periodic
+// transaction occurs at the start of every one of its periods, such as the
+// first day of the month for Monthly, within its own Start and End dates. When
+// trans is empty, periodic transactions are forecast from their Start, or from
+// today if they have none.
+//
+// The generated transactions have Forecast set and are sorted by date.
+func Forecast(trans []*Transaction, periodic []*PeriodicTransaction, end time.Time) []*Transaction {
+	_, last := startEndTime(trans)
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var forecast []*Transaction
+	for _, ptrans := range periodic {
+		// from is the first date a transaction may be generated on
+		from := ptrans.Start
+		if len(trans) == 0 && from.IsZero() {
+			from = today
+		}
+		after := from
+		if last.After(after) {
+			after = last
+		}
+		for _, boundary := range getDateBoundaries(ptrans.Period, after, end) {
+			if (len(trans) > 0 && !boundary.After(last)) || boundary.Before(from) || boundary.After(end) {
+				continue
+			}
+			if !ptrans.End.IsZero() && !boundary.Before(ptrans.End) {
+				continue
+			}
+			payee := ptrans.Payee
+			if payee == "" {
+				payee = "Forecast transaction"
+			}
+			generated := &Transaction{Payee: payee, Date: boundary, Forecast: true}
+			for _, accChange := range ptrans.AccountChanges {
+				accChange.Balance = accChange.Balance.Clone()
+				generated.AccountChanges = append(generated.AccountChanges, accChange)
+			}
+			forecast = append(forecast, generated)
+		}
+	}
+
+	sort.SliceStable(forecast, func(i, j int) bool {
+		return forecast[i].Date.Before(forecast[j].Date)
+	})
+	return forecast
+}
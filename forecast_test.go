@@ -0,0 +1,90 @@
+package ledger
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestForecast(t *testing.T) {
+	data := `~ Monthly  Salary
+	Assets:Checking  $3000.00
+	Income:Salary
+
+~ Monthly from 2026/03/01 to 2026/05/01  Loan payment
+	Liabilities:Loan  $500.00
+	Assets:Checking
+
+2026/01/05 Opening
+	Assets:Checking  $1000.00
+	Equity:Opening
+`
+	journal, err := ParseJournal(bytes.NewBufferString(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loan := journal.PeriodicTransactions[1]
+	if loan.Payee != "Loan payment" || loan.Start.Month() != time.March || loan.End.Month() != time.May {
+		t.Fatalf("unexpected periodic transaction %+v", loan)
+	}
+
+	forecast := Forecast(journal.Transactions, journal.PeriodicTransactions, time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC))
+	expected := []struct {
+		payee string
+		month time.Month
+	}{
+		{"Salary", time.February},
+		{"Salary", time.March},
+		{"Loan payment", time.March},
+		{"Salary", time.April},
+		{"Loan payment", time.April},
+		{"Salary", time.May},
+	}
+	if len(forecast) != len(expected) {
+		t.Fatalf("expected %d forecast transactions, got %d", len(expected), len(forecast))
+	}
+	for i, trans := range forecast {
+		if trans.Payee != expected[i].payee || trans.Date.Month() != expected[i].month || trans.Date.Day() != 1 || !trans.Forecast {
+			t.Errorf("forecast %d: expected %s on %s 1, got %s on %s", i, expected[i].payee, expected[i].month, trans.Payee, trans.Date.Format("2006/01/02"))
+		}
+	}
+
+	// Generated account changes must not share amounts with the definition
+	forecast[0].AccountChanges[0].Balance["$"].SetInt64(0)
+	if journal.PeriodicTransactions[0].AccountChanges[0].Balance["$"].Sign() == 0 {
+		t.Error("forecast transaction shares its balance with the periodic transaction")
+	}
+}
+
+func TestForecastWithoutTransactions(t *testing.T) {
+	data := `~ Monthly from 2026/03/01 to 2026/05/01  Loan payment
+	Liabilities:Loan  $500.00
+	Assets:Checking
+
+~ Monthly  Salary
+	Assets:Checking  $3000.00
+	Income:Salary
+`
+	journal, err := ParseJournal(bytes.NewBufferString(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Periodic transactions start from their own start date
+	forecast := Forecast(nil, journal.PeriodicTransactions[:1], time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC))
+	if len(forecast) != 2 || !forecast[0].Date.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) ||
+		!forecast[1].Date.Equal(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected loan payments on 2026/03/01 and 2026/04/01, got %d transactions", len(forecast))
+	}
+
+	// or from today when they have none
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	forecast = Forecast(nil, journal.PeriodicTransactions[1:], today.AddDate(1, 0, 0))
+	if len(forecast) < 12 || len(forecast) > 13 {
+		t.Fatalf("expected a year of salaries, got %d transactions", len(forecast))
+	}
+	if forecast[0].Date.Before(today) {
+		t.Errorf("expected no salary before today, got one on %s", forecast[0].Date.Format("2006/01/02"))
+	}
+}
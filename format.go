@@ -0,0 +1,135 @@
+package ledger
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	date "github.com/joyt/godate"
+)
+
+// postingIndent is the indentation of account changes and of the lines of a
+// directive in a formatted file.
+const postingIndent = "    "
+
+// Format rewrites the lines of tree in the canonical layout of a ledger file:
+//
+//   - dates are written as 2006/01/02, or 01/02 when they have no year
+//   - account changes and the lines of directives are indented by four spaces
+//   - amounts are right aligned to end at amountColumn, with at least two
+//     spaces after the account name
+//   - runs of blank lines are collapsed into one, blank lines at the start and
+//     end of the file are removed, and no line ends with whitespace
+//
+// Comments, directives and amounts are kept as written, so the file parses to
+// the same transactions.
+func (tree *SyntaxTree) Format(amountColumn int) {
+	var nodes []*SyntaxNode
+	for _, node := range tree.Nodes {
+		if node.Kind == BlankNode && (len(nodes) == 0 || nodes[len(nodes)-1].Kind == BlankNode) {
+			continue
+		}
+		node.format(amountColumn)
+		nodes = append(nodes, node)
+	}
+	for len(nodes) > 0 && nodes[len(nodes)-1].Kind == BlankNode {
+		nodes = nodes[:len(nodes)-1]
+	}
+	tree.Nodes = nodes
+
+	// The file ends with a line ending like its first line's
+	if len(nodes) > 0 {
+		last := nodes[len(nodes)-1]
+		for len(last.Children) > 0 {
+			last = last.Children[len(last.Children)-1]
+		}
+		if last.Newline == "" {
+			last.Newline = nodes[0].Newline
+			if last.Newline == "" {
+				last.Newline = "\n"
+			}
+		}
+	}
+}
+
+func (node *SyntaxNode) format(amountColumn int) {
+	switch node.Kind {
+	case BlankNode:
+		node.Text = ""
+	case CommentNode:
+		node.Text = node.Comment
+	case DirectiveNode, AutomatedTransactionNode, PeriodicTransactionNode:
+		node.Text = joinLine(node.Keyword, node.Value, node.Comment)
+	case TransactionNode:
+		if len(node.Payee) > 0 {
+			node.Date = formatDate(node.Date)
+			node.Text = joinLine(node.Date, node.Payee, node.Comment)
+		}
+	}
+	node.Indent = ""
+
+	for _, child := range node.Children {
+		child.Indent = postingIndent
+		switch child.Kind {
+		case CommentNode:
+			child.Text = postingIndent + child.Comment
+		case DirectiveNode:
+			child.Text = postingIndent + joinLine(child.Keyword, child.Value, child.Comment)
+		case PostingNode:
+			child.Text = child.formatPosting(amountColumn)
+		}
+	}
+}
+
+// formatPosting returns the line of an account change with its amount right
+// aligned to end at amountColumn.
+func (node *SyntaxNode) formatPosting(amountColumn int) string {
+	text := postingIndent + node.Account
+	switch {
+	case len(node.Amount) > 0:
+		spaceCount := amountColumn - utf8.RuneCountInString(text) - utf8.RuneCountInString(node.Amount)
+		if spaceCount < 2 {
+			spaceCount = 2
+		}
+		text += strings.Repeat(" ", spaceCount) + node.Amount
+		if len(node.Assertion) > 0 {
+			text += " " + formatAssertion(node.Assertion)
+		}
+	case len(node.Assertion) > 0:
+		text += "  " + formatAssertion(node.Assertion)
+	}
+	if len(node.Comment) > 0 {
+		text += "  " + node.Comment
+	}
+	return text
+}
+
+// formatAssertion returns a balance assertion with a single space after "=".
+func formatAssertion(assertion string) string {
+	return "= " + strings.TrimLeft(assertion[1:], whitespace)
+}
+
+// joinLine returns the line made of a keyword or date, the rest of the line
+// and a comment.
+func joinLine(keyword, value, comment string) string {
+	text := keyword
+	if len(value) > 0 {
+		text += " " + value
+	}
+	if len(comment) > 0 {
+		text += "  " + comment
+	}
+	return text
+}
+
+// formatDate returns s written as 2006/01/02, or 01/02 when it has no year, or
+// s itself when it can not be parsed.
+func formatDate(s string) string {
+	d, err := date.Parse(s)
+	if err != nil {
+		return s
+	}
+	if d.Year() == 0 {
+		return d.Format("01/02")
+	}
+	return d.Format("2006/01/02")
+}
@@ -0,0 +1,93 @@
+package ledger
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+const unformattedLedger = "\n" +
+	"; Household ledger   \n" +
+	"account   Assets:Checking\n" +
+	"\tnote Main account\n" +
+	"\n" +
+	"\n" +
+	"2026-1-5 * (1234) Grocery Store ; :food:\n" +
+	"  ; Receipt: 42\n" +
+	"  Expenses:Food\t($10.50 * 3)\n" +
+	"\t! Assets:Checking  $-31.50   =  $968.50;checked\n" +
+	"   \t\n" +
+	"~   Monthly\n" +
+	"  Expenses:Rent    $500\n" +
+	"  Assets:Checking\n" +
+	"\n" +
+	"year 2026\n" +
+	"3/1 Bakery\n" +
+	"\tExpenses:Food:Bread and Pastries:Croissants  $5\n" +
+	"\tAssets:Checking\n" +
+	"\n\n"
+
+const formattedLedger = "; Household ledger\n" +
+	"account Assets:Checking\n" +
+	"    note Main account\n" +
+	"\n" +
+	"2026/01/05 * (1234) Grocery Store  ; :food:\n" +
+	"    ; Receipt: 42\n" +
+	"    Expenses:Food           ($10.50 * 3)\n" +
+	"    ! Assets:Checking            $-31.50 = $968.50  ;checked\n" +
+	"\n" +
+	"~ Monthly\n" +
+	"    Expenses:Rent                   $500\n" +
+	"    Assets:Checking\n" +
+	"\n" +
+	"year 2026\n" +
+	"03/01 Bakery\n" +
+	"    Expenses:Food:Bread and Pastries:Croissants  $5\n" +
+	"    Assets:Checking\n"
+
+func TestFormat(t *testing.T) {
+	tree, err := ParseSyntax(bytes.NewBufferString(unformattedLedger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.Format(40)
+	if tree.String() != formattedLedger {
+		t.Errorf("expected\n%s\ngot\n%s", formattedLedger, tree.String())
+	}
+
+	expected, _ := ParseLedger(bytes.NewBufferString(unformattedLedger))
+	got, _ := ParseLedger(bytes.NewBufferString(formattedLedger))
+	exp, _ := json.Marshal(expected)
+	gotJSON, _ := json.Marshal(got)
+	if string(exp) != string(gotJSON) {
+		t.Errorf("expected the same transactions \n`%s`, \ngot \n`%s`", exp, gotJSON)
+	}
+
+	// Formatting a formatted file changes nothing
+	tree, _ = ParseSyntax(bytes.NewBufferString(formattedLedger))
+	tree.Format(40)
+	if tree.String() != formattedLedger {
+		t.Errorf("expected no change, got\n%s", tree.String())
+	}
+}
+
+func TestFormatKeepsTransactions(t *testing.T) {
+	for _, tc := range testCases {
+		tree, err := ParseSyntax(bytes.NewBufferString(tc.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree.Format(79)
+
+		expected, expErr := ParseLedger(bytes.NewBufferString(tc.data))
+		got, gotErr := ParseLedger(bytes.NewBufferString(tree.String()))
+		if (expErr == nil) != (gotErr == nil) {
+			t.Errorf("expected error %v, got %v on\n%s", expErr, gotErr, tree)
+		}
+		exp, _ := json.Marshal(expected)
+		gotJSON, _ := json.Marshal(got)
+		if string(exp) != string(gotJSON) {
+			t.Errorf("expected \n`%s`, \ngot \n`%s` on\n%s", exp, gotJSON, tree)
+		}
+	}
+}
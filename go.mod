@@ -1,10 +1,9 @@
 module github.com/pedroalbanese/ledger
 
-go 1.12
+go 1.16
 
 require (
 	github.com/hako/durafmt v0.0.0-20210608085754-5c1018a4e16b
 	github.com/jbrukh/bayesian v0.0.0-20200318221351-d726b684ca4a
 	github.com/joyt/godate v0.0.0-20150226210126-7151572574a7
-	github.com/marcmak/calc v0.0.0-20150509200512-5bbbfc3b3149
 )
@@ -4,7 +4,10 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -14,80 +17,208 @@ const (
 	markerPrefix = ";__ledger_file"
 )
 
-var includedFiles = make(map[string]bool)
-
+// NewLedgerReader reads filename along with the files it includes, adding
+// marker comments that tell the parser which file and line each line comes
+// from. It may be called from several goroutines at once.
 func NewLedgerReader(filename string) (*bytes.Buffer, error) {
-	var buf bytes.Buffer
+	l := &loader{files: osFiles{}}
+	err := l.includeFile(filename)
+	return &l.buf, err
+}
+
+// NewLedgerReaderFS reads filename like NewLedgerReader, but from fsys, where
+// the files it includes are also read from. File names are slash-separated
+// paths in fsys, as fs.FS expects, so included files can not be outside of it.
+func NewLedgerReaderFS(fsys fs.FS, filename string) (*bytes.Buffer, error) {
+	l := &loader{files: fsFiles{fsys}}
+	err := l.includeFile(filename)
+	return &l.buf, err
+}
+
+// ResolveInclude returns the path of the file, or pattern, that an include
+// directive of filename names as name, the way NewLedgerReader finds it:
+// relative to filename unless it is absolute or starts with "~", the home
+// directory.
+func ResolveInclude(filename, name string) (string, error) {
+	return osFiles{}.Resolve(filename, name)
+}
+
+// ledgerFiles is where a ledger file and the files it includes are read from.
+type ledgerFiles interface {
+	Open(name string) (io.ReadCloser, error)
+	Glob(pattern string) ([]string, error)
+	Clean(name string) string
+	// Resolve returns the path of name, included by filename.
+	Resolve(filename, name string) (string, error)
+}
+
+// osFiles reads files from the operating system. Included files may be given
+// as absolute paths, or from the home directory with "~".
+type osFiles struct{}
+
+func (osFiles) Open(name string) (io.ReadCloser, error) { return os.Open(name) }
+func (osFiles) Glob(pattern string) ([]string, error)   { return filepath.Glob(pattern) }
+func (osFiles) Clean(name string) string                { return filepath.Clean(name) }
+
+func (osFiles) Resolve(filename, name string) (string, error) {
+	if name == "~" || strings.HasPrefix(name, "~/") || strings.HasPrefix(name, "~"+string(filepath.Separator)) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, name[1:]), nil
+	}
+	if filepath.IsAbs(name) {
+		return filepath.Clean(name), nil
+	}
+	return filepath.Join(filename, "..", name), nil
+}
+
+// fsFiles reads files from an fs.FS. Included files given as absolute paths
+// are taken from the root of the file system.
+type fsFiles struct {
+	fsys fs.FS
+}
+
+func (f fsFiles) Open(name string) (io.ReadCloser, error) { return f.fsys.Open(name) }
+func (fsFiles) Clean(name string) string                  { return path.Clean(name) }
 
-	err := includeFile(filename, &buf)
-	return &buf, err
+func (fsFiles) Resolve(filename, name string) (string, error) {
+	if strings.HasPrefix(name, "/") {
+		return path.Clean(name[1:]), nil
+	}
+	return path.Join(filename, "..", name), nil
+}
+
+func (f fsFiles) Glob(pattern string) ([]string, error) {
+	// fs.Glob finds no match for such paths, but they must not go unnoticed
+	if !fs.ValidPath(pattern) {
+		return nil, &fs.PathError{Op: "include", Path: pattern, Err: fs.ErrInvalid}
+	}
+	return fs.Glob(f.fsys, pattern)
+}
+
+// loader reads a ledger file and the files it includes into buf. Each call of
+// NewLedgerReader has its own loader.
+type loader struct {
+	files ledgerFiles
+	buf   bytes.Buffer
+	// stack holds the files being read, each one included by the one before
+	stack []string
 }
 
 // includeFile reads filename into buf, adding special marker comments
 // when there are step changes in file location due to 'include' directive.
-func includeFile(filename string, buf *bytes.Buffer) error {
-	filename = filepath.Clean(filename)
+func (l *loader) includeFile(filename string) error {
+	filename = l.files.Clean(filename)
 	lineNum := 0
+	buf := &l.buf
 
 	// check for include cyles
-	if includedFiles[filename] {
-		return fmt.Errorf("include cycle: '%s'", filename)
-	} else {
-		includedFiles[filename] = true
+	for i, name := range l.stack {
+		if name == filename {
+			cycle := append(append([]string(nil), l.stack[i:]...), filename)
+			return fmt.Errorf("include cycle: %s", strings.Join(cycle, " -> "))
+		}
 	}
+	l.stack = append(l.stack, filename)
+	defer func() {
+		l.stack = l.stack[:len(l.stack)-1]
+	}()
 
-	defer delete(includedFiles, filename)
-
-	f, err := os.Open(filename)
+	f, err := l.files.Open(filename)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
+	var lineSize int
 	s := bufio.NewScanner(f)
+	s.Split(scanLines(&lineSize))
+	offset := 0
 
 	// mark the start of this file
-	fmt.Fprintln(buf, marker(filename, lineNum))
+	fmt.Fprintln(buf, marker(filename, lineNum, offset))
 
 	for s.Scan() {
 		line := s.Text()
+		offset += lineSize
 
 		if strings.HasPrefix(line, "include") {
 			pieces := strings.Split(line, " ")
 			if len(pieces) != 2 {
-				return fmt.Errorf("%s:%d: invalid include directive", filename, lineNum)
+				return fmt.Errorf("%s:%d: invalid include directive", filename, lineNum+1)
 			}
 
 			// Resolve filepaths
-			includedPath := filepath.Join(filename, "..", pieces[1])
-			includedPaths, err := filepath.Glob(includedPath)
+			includedPath, err := l.files.Resolve(filename, pieces[1])
+			var includedPaths []string
+			if err == nil {
+				includedPaths, err = l.files.Glob(includedPath)
+			}
 
-			// Include all resolved filepaths
+			// Include all resolved filepaths, marking the resumption point for
+			// this file after each one so the end of every included file is known.
+			// A pattern may match a file being read, which is left out, but
+			// naming one is a cycle.
+			resumed := false
 			for i := 0; i < len(includedPaths) && err == nil; i++ {
-				if !includedFiles[includedPaths[i]] {
-					err = includeFile(includedPaths[i], buf)
+				if hasGlobMeta(pieces[1]) && l.reading(includedPaths[i]) {
+					continue
 				}
+				err = l.includeFile(includedPaths[i])
+				fmt.Fprintln(buf, marker(filename, lineNum+1, offset))
+				resumed = true
 			}
 			if err != nil {
-				return fmt.Errorf("%s:%d: %s", filename, lineNum, err.Error())
+				return fmt.Errorf("%s:%d: %s", filename, lineNum+1, err.Error())
 			}
 			lineNum++
 
 			// mark the resumption point for this file
-			fmt.Fprintln(buf, marker(filename, lineNum))
+			if !resumed {
+				fmt.Fprintln(buf, marker(filename, lineNum, offset))
+			}
 		} else {
-			fmt.Fprintln(buf, s.Text())
+			// Keep a CRLF line ending so that the parser finds the same byte
+			// offsets as in the file
+			if lineSize-len(line) == 2 {
+				line += "\r"
+			}
+			fmt.Fprintln(buf, line)
 			lineNum++
 		}
 	}
 	return nil
 }
 
-func marker(filename string, lineNum int) string {
-	return fmt.Sprintf("%s*-*%s*-*%d", markerPrefix, filename, lineNum)
+// reading tells whether filename is being read, as an including file.
+func (l *loader) reading(filename string) bool {
+	for _, name := range l.stack {
+		if name == l.files.Clean(filename) {
+			return true
+		}
+	}
+	return false
 }
 
-func parseMarker(s string) (string, int) {
+// hasGlobMeta tells whether an included path is a pattern.
+func hasGlobMeta(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// marker returns the comment telling that the next line is line lineNum + 1
+// of filename, starting at byte offset.
+func marker(filename string, lineNum, offset int) string {
+	return fmt.Sprintf("%s*-*%s*-*%d*-*%d", markerPrefix, filename, lineNum, offset)
+}
+
+// parseMarker returns the file name, line number and byte offset of a marker.
+// Markers written without an offset give 0.
+func parseMarker(s string) (filename string, lineNum, offset int) {
 	v := strings.Split(s, "*-*")
-	lineNum, _ := strconv.Atoi(v[2])
-	return v[1], lineNum
+	lineNum, _ = strconv.Atoi(v[2])
+	if len(v) > 3 {
+		offset, _ = strconv.Atoi(v[3])
+	}
+	return v[1], lineNum, offset
 }
@@ -2,9 +2,14 @@ package ledger
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
 )
 
 func TestLedgerScannerBasic(t *testing.T) {
@@ -71,11 +76,158 @@ func TestLedgerScannerWildcardInclude(t *testing.T) {
 }
 
 func TestMarkerSplit(t *testing.T) {
-	filename, lineNum := parseMarker(";__ledger_file*-*/somedir/somefile*-*45")
+	filename, lineNum, offset := parseMarker(";__ledger_file*-*/somedir/somefile*-*45")
 	if filename != "/somedir/somefile" {
 		t.Fatalf("expected: %s got:%s", "/somedir/somefile", filename)
 	}
 	if lineNum != 45 {
 		t.Fatalf("expected: %d got:%d", 45, lineNum)
 	}
+	if offset != 0 {
+		t.Fatalf("expected: %d got:%d", 0, offset)
+	}
+
+	filename, lineNum, offset = parseMarker(marker("/somedir/somefile", 45, 1024))
+	if filename != "/somedir/somefile" || lineNum != 45 || offset != 1024 {
+		t.Fatalf("expected: /somedir/somefile 45 1024 got:%s %d %d", filename, lineNum, offset)
+	}
+}
+
+func TestLedgerReaderFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"journals/root.ledger":      {Data: []byte("2026/01/05 Grocery Store\n\tExpenses:Food  $10\n\tAssets:Checking\n\ninclude months/*.ledger\n")},
+		"journals/months/01.ledger": {Data: []byte("2026/01/06 Bakery\n\tExpenses:Food  $5\n\tAssets:Checking\n")},
+		"journals/months/02.ledger": {Data: []byte("2026/02/01 Rent\n\tExpenses:Rent  $500\n\tAssets:Checking\n")},
+	}
+
+	r, err := NewLedgerReaderFS(fsys, "journals/root.ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	generalLedger, err := ParseLedger(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(generalLedger) != 3 {
+		t.Fatalf("expected 3 transactions, got %d", len(generalLedger))
+	}
+	expectedFiles := []string{"journals/root.ledger", "journals/months/01.ledger", "journals/months/02.ledger"}
+	for i, trans := range generalLedger {
+		if trans.Position.File != expectedFiles[i] || trans.Position.StartLine != 1 {
+			t.Errorf("%s: expected %s:1, got %s", trans.Payee, expectedFiles[i], trans.Position)
+		}
+	}
+}
+
+func TestLedgerReaderFSOutside(t *testing.T) {
+	fsys := fstest.MapFS{
+		"root.ledger": {Data: []byte("include ../secret.ledger\n")},
+	}
+	if _, err := NewLedgerReaderFS(fsys, "root.ledger"); err == nil {
+		t.Error("expected an error including a file outside of the file system")
+	}
+}
+
+func TestLedgerReaderCycle(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.ledger": {Data: []byte("include b.ledger\n")},
+		"b.ledger": {Data: []byte("2026/01/05 Grocery Store\n\tExpenses:Food  $10\n\tAssets:Checking\n\ninclude a.ledger\n")},
+	}
+	_, err := NewLedgerReaderFS(fsys, "a.ledger")
+	if err == nil || !strings.HasSuffix(err.Error(), "include cycle: a.ledger -> b.ledger -> a.ledger") {
+		t.Errorf("expected the include cycle, got %v", err)
+	}
+}
+
+func TestLedgerReaderIncludePaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	home := filepath.Join(dir, "home")
+	if err := os.MkdirAll(filepath.Join(home, "journals"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldHome := os.Getenv("HOME")
+	os.Setenv("HOME", home)
+	defer os.Setenv("HOME", oldHome)
+
+	files := map[string]string{
+		filepath.Join(dir, "root.ledger"):              "include " + filepath.Join(dir, "absolute.ledger") + "\ninclude ~/journals/home.ledger\n",
+		filepath.Join(dir, "absolute.ledger"):          "2026/01/05 Grocery Store\n\tExpenses:Food  $10\n\tAssets:Checking\n",
+		filepath.Join(home, "journals", "home.ledger"): "2026/01/06 Bakery\n\tExpenses:Food  $5\n\tAssets:Checking\n",
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := NewLedgerReader(filepath.Join(dir, "root.ledger"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	generalLedger, err := ParseLedger(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(generalLedger) != 2 ||
+		generalLedger[0].Position.File != filepath.Join(dir, "absolute.ledger") ||
+		generalLedger[1].Position.File != filepath.Join(home, "journals", "home.ledger") {
+		t.Errorf("expected the transactions of both included files, got %d", len(generalLedger))
+	}
+
+	root := filepath.Join(dir, "root.ledger")
+	for name, expected := range map[string]string{
+		"~/journals/home.ledger":              filepath.Join(home, "journals", "home.ledger"),
+		filepath.Join(dir, "absolute.ledger"): filepath.Join(dir, "absolute.ledger"),
+		"journals/*.ledger":                   filepath.Join(dir, "journals", "*.ledger"),
+	} {
+		if got, err := ResolveInclude(root, name); err != nil || got != expected {
+			t.Errorf("expected %s to resolve to %s, got %s, %v", name, expected, got, err)
+		}
+	}
+}
+
+func TestLedgerReaderFSAbsolute(t *testing.T) {
+	fsys := fstest.MapFS{
+		"journals/root.ledger": {Data: []byte("include /shared/prices.ledger\n")},
+		"shared/prices.ledger": {Data: []byte("P 2026/01/01 VWRA $100\n")},
+	}
+	r, err := NewLedgerReaderFS(fsys, "journals/root.ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(r.String(), marker("shared/prices.ledger", 0, 0)) {
+		t.Errorf("expected shared/prices.ledger to be included, got\n%s", r)
+	}
+}
+
+func TestLedgerReaderConcurrent(t *testing.T) {
+	expected, err := NewLedgerReader("testdata/ledgerReader_input_1_root")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := NewLedgerReader("testdata/ledgerReader_input_1_root")
+			if err == nil && r.String() != expected.String() {
+				err = fmt.Errorf("unexpected output\n%s", r)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
 }
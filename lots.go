@@ -0,0 +1,265 @@
+package ledger
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// LotMethod selects which lots a sale takes from when it does not name a lot.
+type LotMethod string
+
+// Lot selection methods supported by ledger
+const (
+	LotFIFO LotMethod = "FIFO" // oldest lots first
+	LotLIFO LotMethod = "LIFO" // newest lots first
+)
+
+// Holding is the quantity of a lot still held in an account.
+type Holding struct {
+	Account   string
+	Commodity string
+	Quantity  *big.Rat
+	Lot       Lot
+}
+
+// RealizedGain is the gain (or loss, when negative) from selling part or all
+// of a lot.
+type RealizedGain struct {
+	Date      time.Time
+	Payee     string
+	Account   string
+	Commodity string
+	Quantity  *big.Rat
+	Lot       Lot
+	Proceeds  Amount
+	Basis     Amount
+	Gain      Amount
+}
+
+// UnrealizedGain is the gain (or loss, when negative) of a holding valued at
+// market price. Value and Gain are nil when no market price is known.
+type UnrealizedGain struct {
+	Holding
+	Basis Amount
+	Value *Amount
+	Gain  *Amount
+}
+
+// GainsReport holds the realized gains of every sale and the unrealized gains
+// of the lots still held at the report date.
+type GainsReport struct {
+	Date       time.Time
+	Realized   []RealizedGain
+	Unrealized []UnrealizedGain
+}
+
+// lotInventory is the list of holdings of one commodity in one account, in
+// acquisition order.
+type lotInventory []*Holding
+
+// CapitalGains walks the transactions in order, keeping the lots held by each
+// account, and reports realized gains for every sale along with the
+// unrealized gains at date using the market prices in prices. Transactions
+// after date are ignored.
+//
+// An account change adds a lot when it increases a commodity and has a lot
+// price or a cost. A decrease takes from the named lot if it has one, then
+// from the account's other lots following method. A decrease with a cost is a
+// sale and realizes a gain; one without is a transfer at cost. Taking more
+// than the lots hold takes quantity acquired without a lot or cost, which has
+// no cost basis: its realized gain, with the zero Lot, is the whole proceeds.
+func CapitalGains(generalLedger []*Transaction, method LotMethod, prices *PriceDB, date time.Time) (*GainsReport, error) {
+	report := &GainsReport{Date: date}
+	inventories := make(map[string]map[string]lotInventory)
+
+	for _, trans := range generalLedger {
+		if trans.Date.After(date) {
+			continue
+		}
+		for _, accChange := range trans.AccountChanges {
+			amounts := accChange.Balance.Amounts()
+			if len(amounts) != 1 {
+				continue
+			}
+			commodity, quantity := amounts[0].Commodity, amounts[0].Quantity
+
+			accInventories, ok := inventories[accChange.Name]
+			if !ok {
+				accInventories = make(map[string]lotInventory)
+				inventories[accChange.Name] = accInventories
+			}
+
+			if quantity.Sign() > 0 {
+				var lot Lot
+				switch {
+				case accChange.Lot != nil:
+					lot = *accChange.Lot
+				case accChange.Cost != nil:
+					lot.Price = Amount{
+						Quantity:  new(big.Rat).Quo(accChange.Cost.Quantity, quantity),
+						Commodity: accChange.Cost.Commodity,
+					}
+				default:
+					continue
+				}
+				if lot.Date.IsZero() {
+					lot.Date = trans.Date
+				}
+				accInventories[commodity] = append(accInventories[commodity], &Holding{
+					Account:   accChange.Name,
+					Commodity: commodity,
+					Quantity:  new(big.Rat).Set(quantity),
+					Lot:       lot,
+				})
+				continue
+			}
+
+			// Commodities never acquired in a lot, such as currencies, are not
+			// tracked
+			if _, ok := accInventories[commodity]; !ok {
+				continue
+			}
+			taken, inventory, err := takeLots(accInventories[commodity], commodity, new(big.Rat).Neg(quantity), accChange.Lot, method)
+			if err != nil {
+				return report, fmt.Errorf("%s %s: %s: %s", trans.Date.Format("2006/01/02"), trans.Payee, accChange.Name, err)
+			}
+			accInventories[commodity] = inventory
+
+			if accChange.Cost == nil {
+				continue
+			}
+			unitProceeds := new(big.Rat).Quo(accChange.Cost.Quantity, quantity)
+			for _, h := range taken {
+				gain := RealizedGain{
+					Date:      trans.Date,
+					Payee:     trans.Payee,
+					Account:   accChange.Name,
+					Commodity: commodity,
+					Quantity:  h.Quantity,
+					Lot:       h.Lot,
+					Proceeds: Amount{
+						Quantity:  new(big.Rat).Mul(unitProceeds, h.Quantity),
+						Commodity: accChange.Cost.Commodity,
+					},
+				}
+				basis, ok := Amount{Quantity: new(big.Rat), Commodity: gain.Proceeds.Commodity}, true
+				if h.Lot.Price.Quantity != nil {
+					basis, ok = prices.Value(Amount{
+						Quantity:  new(big.Rat).Mul(h.Lot.Price.Quantity, h.Quantity),
+						Commodity: h.Lot.Price.Commodity,
+					}, gain.Proceeds.Commodity, trans.Date)
+				}
+				if !ok {
+					return report, fmt.Errorf("%s %s: %s: no price to value %s in %s",
+						trans.Date.Format("2006/01/02"), trans.Payee, accChange.Name, h.Lot.Price.Commodity, gain.Proceeds.Commodity)
+				}
+				gain.Basis = basis
+				gain.Gain = Amount{
+					Quantity:  new(big.Rat).Sub(gain.Proceeds.Quantity, basis.Quantity),
+					Commodity: gain.Proceeds.Commodity,
+				}
+				report.Realized = append(report.Realized, gain)
+			}
+		}
+	}
+
+	for _, accInventories := range inventories {
+		for _, inventory := range accInventories {
+			for _, h := range inventory {
+				unrealized := UnrealizedGain{
+					Holding: *h,
+					Basis: Amount{
+						Quantity:  new(big.Rat).Mul(h.Lot.Price.Quantity, h.Quantity),
+						Commodity: h.Lot.Price.Commodity,
+					},
+				}
+				if value, ok := prices.Value(Amount{Quantity: h.Quantity, Commodity: h.Commodity}, h.Lot.Price.Commodity, date); ok {
+					unrealized.Value = &value
+					unrealized.Gain = &Amount{
+						Quantity:  new(big.Rat).Sub(value.Quantity, unrealized.Basis.Quantity),
+						Commodity: value.Commodity,
+					}
+				}
+				report.Unrealized = append(report.Unrealized, unrealized)
+			}
+		}
+	}
+	sort.SliceStable(report.Unrealized, func(i, j int) bool {
+		a, b := report.Unrealized[i], report.Unrealized[j]
+		if a.Account != b.Account {
+			return a.Account < b.Account
+		}
+		if a.Commodity != b.Commodity {
+			return a.Commodity < b.Commodity
+		}
+		return a.Lot.Date.Before(b.Lot.Date)
+	})
+
+	return report, nil
+}
+
+// takeLots removes quantity of commodity from inventory, first from lots matching lot when
+// it is given and then following method. It returns the holdings taken and
+// what is left of the inventory. What the lots do not hold is taken last as a
+// holding with the zero Lot.
+func takeLots(inventory lotInventory, commodity string, quantity *big.Rat, lot *Lot, method LotMethod) ([]*Holding, lotInventory, error) {
+	order := make([]int, len(inventory))
+	for i := range inventory {
+		order[i] = i
+	}
+	if method == LotLIFO {
+		for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+			order[i], order[j] = order[j], order[i]
+		}
+	}
+	if lot != nil {
+		matched := false
+		sort.SliceStable(order, func(i, j int) bool {
+			return lotMatches(inventory[order[i]].Lot, lot) && !lotMatches(inventory[order[j]].Lot, lot)
+		})
+		for _, idx := range order {
+			matched = matched || lotMatches(inventory[idx].Lot, lot)
+		}
+		if !matched {
+			return nil, inventory, fmt.Errorf("no lot of %s held at %s", commodity, lot.Price.FloatString(2))
+		}
+	}
+
+	var taken []*Holding
+	remaining := new(big.Rat).Set(quantity)
+	for _, idx := range order {
+		if remaining.Sign() == 0 {
+			break
+		}
+		h := inventory[idx]
+		part := new(big.Rat).Set(h.Quantity)
+		if part.Cmp(remaining) > 0 {
+			part.Set(remaining)
+		}
+		h.Quantity.Sub(h.Quantity, part)
+		remaining.Sub(remaining, part)
+		taken = append(taken, &Holding{Account: h.Account, Commodity: h.Commodity, Quantity: part, Lot: h.Lot})
+	}
+	if remaining.Sign() > 0 {
+		taken = append(taken, &Holding{Commodity: commodity, Quantity: remaining})
+	}
+
+	var left lotInventory
+	for _, h := range inventory {
+		if h.Quantity.Sign() != 0 {
+			left = append(left, h)
+		}
+	}
+	return taken, left, nil
+}
+
+// lotMatches reports whether held is the lot named by lot, ignoring the date
+// when lot does not give one.
+func lotMatches(held Lot, lot *Lot) bool {
+	if held.Price.Commodity != lot.Price.Commodity || held.Price.Quantity.Cmp(lot.Price.Quantity) != 0 {
+		return false
+	}
+	return lot.Date.IsZero() || held.Date.Equal(lot.Date)
+}
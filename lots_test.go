@@ -0,0 +1,93 @@
+package ledger
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestCapitalGains(t *testing.T) {
+	data := `P 2026/06/01 AAPL $200
+
+2026/01/05 Buy
+	Assets:Broker  10 AAPL @ $100
+	Assets:Cash
+
+2026/02/05 Buy
+	Assets:Broker  10 AAPL {$120} [2026/02/01]
+	Assets:Cash
+
+2026/03/01 Sell
+	Assets:Broker  -5 AAPL {$120} @ $180
+	Assets:Cash  $900
+
+2026/04/01 Sell
+	Assets:Broker  -8 AAPL @@ $1200
+	Assets:Cash
+`
+	journal, err := ParseJournal(bytes.NewBufferString(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cash := journal.Transactions[1].AccountChanges[1].Balance["$"]; cash.Cmp(big.NewRat(-1200, 1)) != 0 {
+		t.Errorf("expected lot price to balance the purchase, got %s", cash)
+	}
+
+	date := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		method     LotMethod
+		realized   []int64
+		unrealized []int64
+	}{
+		{LotFIFO, []int64{300, 400}, []int64{200, 400}},
+		{LotLIFO, []int64{300, 150, 150}, []int64{700}},
+	}
+	for _, tc := range tests {
+		report, err := CapitalGains(journal.Transactions, tc.method, journal.Prices, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Realized) != len(tc.realized) {
+			t.Fatalf("%s: expected %d realized gains, got %d", tc.method, len(tc.realized), len(report.Realized))
+		}
+		for i, gain := range report.Realized {
+			if gain.Gain.Commodity != "$" || gain.Gain.Quantity.Cmp(big.NewRat(tc.realized[i], 1)) != 0 {
+				t.Errorf("%s: realized gain %d: expected $%d, got %s", tc.method, i, tc.realized[i], gain.Gain.FloatString(2))
+			}
+		}
+		if len(report.Unrealized) != len(tc.unrealized) {
+			t.Fatalf("%s: expected %d holdings, got %d", tc.method, len(tc.unrealized), len(report.Unrealized))
+		}
+		for i, gain := range report.Unrealized {
+			if gain.Gain == nil || gain.Gain.Quantity.Cmp(big.NewRat(tc.unrealized[i], 1)) != 0 {
+				t.Errorf("%s: unrealized gain %d: expected $%d, got %v", tc.method, i, tc.unrealized[i], gain.Gain)
+			}
+		}
+	}
+
+	// Of the 24 AAPL sold, the 14 not bought in a lot have no cost basis
+	oversold := []*Transaction{journal.Transactions[0], journal.Transactions[3], journal.Transactions[3], journal.Transactions[3]}
+	report, err := CapitalGains(oversold, LotFIFO, journal.Prices, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		quantity, basis, gain int64
+	}{
+		{8, 800, 400},
+		{2, 200, 100},
+		{6, 0, 900},
+		{8, 0, 1200},
+	}
+	if len(report.Realized) != len(expected) {
+		t.Fatalf("expected %d realized gains, got %d", len(expected), len(report.Realized))
+	}
+	for i, gain := range report.Realized {
+		if gain.Quantity.Cmp(big.NewRat(expected[i].quantity, 1)) != 0 || gain.Basis.Quantity.Cmp(big.NewRat(expected[i].basis, 1)) != 0 ||
+			gain.Gain.Quantity.Cmp(big.NewRat(expected[i].gain, 1)) != 0 {
+			t.Errorf("realized gain %d: expected %d AAPL, basis $%d, gain $%d, got %s, %s, %s", i, expected[i].quantity, expected[i].basis,
+				expected[i].gain, gain.Quantity.FloatString(2), gain.Basis.FloatString(2), gain.Gain.FloatString(2))
+		}
+	}
+}
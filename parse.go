@@ -2,178 +2,736 @@ package ledger
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"math/big"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	date "github.com/joyt/godate"
-	"github.com/marcmak/calc/calc"
 )
 
 const (
 	whitespace = " \t"
 )
 
+// ParseOption changes how a ledger file is parsed.
+type ParseOption func(*parseConfig)
+
+type parseConfig struct {
+	aliases []*AccountAlias
+	strict  bool
+}
+
+// WithAlias renames accounts with alias after the aliases declared in the
+// ledger file, such as one given on the command line.
+func WithAlias(alias *AccountAlias) ParseOption {
+	return func(config *parseConfig) {
+		config.aliases = append(config.aliases, alias)
+	}
+}
+
+// WithStrictParsing leaves out every transaction with a problem, such as an
+// invalid date, an amount that can not be parsed or an amount separated from
+// the account name by a single space, instead of returning it half parsed.
+// The problems are reported as usual. A date with no year, such as 03/15, is
+// also a problem unless a year directive gives its year.
+func WithStrictParsing() ParseOption {
+	return func(config *parseConfig) {
+		config.strict = true
+	}
+}
+
+func newParseConfig(opts []ParseOption) *parseConfig {
+	config := &parseConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
 // ParseLedger parses a ledger file and returns a list of Transactions.
 //
-// Transactions are sorted by date.
-func ParseLedger(ledgerReader io.Reader) (generalLedger []*Transaction, err error) {
-	parseLedger(ledgerReader, func(t *Transaction, e error) (stop bool) {
-		if e != nil {
-			err = e
-			stop = true
-			return
+// Transactions are sorted by date. Problems are returned in an ErrorList, see
+// ParseJournal.
+func ParseLedger(ledgerReader io.Reader, opts ...ParseOption) (generalLedger []*Transaction, err error) {
+	journal, err := ParseJournal(ledgerReader, opts...)
+	return journal.Transactions, err
+}
+
+// ParseJournal parses a ledger file and returns its transactions along with
+// the information declared by its directives, such as "P" market prices.
+//
+// Transactions are sorted by date. Parsing goes on after a problem is found,
+// and every problem is returned in an ErrorList.
+func ParseJournal(ledgerReader io.Reader, opts ...ParseOption) (journal *Journal, err error) {
+	p := newParser(ledgerReader, newParseConfig(opts))
+	journal = p.journal
+	var errs ErrorList
+	for {
+		trans, err := p.next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		if parseErr, ok := err.(*ParseError); ok {
+			errs = append(errs, parseErr)
+			continue
+		}
+		if err != nil {
+			return journal, err
 		}
 
-		generalLedger = append(generalLedger, t)
-		return
-	})
+		journal.Transactions = append(journal.Transactions, trans)
+	}
 
-	if len(generalLedger) > 1 {
-		sort.Slice(generalLedger, func(i, j int) bool {
-			return generalLedger[i].Date.Before(generalLedger[j].Date)
+	if len(journal.Transactions) > 1 {
+		sort.SliceStable(journal.Transactions, func(i, j int) bool {
+			return journal.Transactions[i].Date.Before(journal.Transactions[j].Date)
 		})
 	}
 
-	return
+	for _, assertErr := range ApplyBalanceAssertions(journal.Transactions) {
+		errs = append(errs, assertErr.(*ParseError))
+	}
+
+	return journal, errs.Err()
 }
 
-// ParseLedgerAsync parses a ledger file and returns a Transaction and error channels .
+// ParseLedgerAsync parses a ledger file and returns a Transaction and error
+// channels, as ParseLedgerAsyncContext does with a context that is never done.
 //
-func ParseLedgerAsync(ledgerReader io.Reader) (c chan *Transaction, e chan error) {
-	c = make(chan *Transaction)
-	e = make(chan error)
-
-	go func() {
-		parseLedger(ledgerReader, func(t *Transaction, err error) (stop bool) {
-			if err != nil {
-				e <- err
-			} else {
-				c <- t
-			}
-			return
-		})
-
-		e <- nil
-	}()
-	return c, e
+// Deprecated: the goroutine sending to the channels never ends unless every
+// transaction and error is received. Use NewParser or ParseLedgerAsyncContext
+// instead.
+func ParseLedgerAsync(ledgerReader io.Reader, opts ...ParseOption) (c chan *Transaction, e chan error) {
+	return ParseLedgerAsyncContext(context.Background(), ledgerReader, opts...)
 }
 
 var accountToAmountSpace = regexp.MustCompile(" {2,}|\t+")
 
-func parseLedger(ledgerReader io.Reader, callback func(t *Transaction, err error) (stop bool)) {
-	var trans *Transaction
-	scanner := bufio.NewScanner(ledgerReader)
-	var line string
-	var filename string
-	var lineCount int
-	var comments []string
+// parser reads a ledger file one line at a time. The transactions and
+// problems found are queued until next returns them, and directives are
+// recorded in journal.
+type parser struct {
+	scanner *bufio.Scanner
+	journal *Journal
+	config  *parseConfig
+	results []parseResult
 
-	errorMsg := func(msg string) (stop bool) {
-		return callback(nil, fmt.Errorf("%s:%d: %s", filename, lineCount, msg))
-	}
+	trans     *Transaction
+	line      string
+	filename  string
+	lineCount int
+	// The byte offsets in the file of the current line and of the next one,
+	// and the size of the line read by scanner
+	lineStart   int
+	offset      int
+	lineSize    int
+	comments    []string
+	pendingTags map[string]string
+	rule        *AutomatedTransaction
+	rules       []*AutomatedTransaction
+	periodic    *PeriodicTransaction
+	// subDirective reads the indented lines after an account or commodity
+	// directive
+	subDirective   func(name, value string) error
+	accountAliases map[string]string
+	aliases        []*AccountAlias
+	scopes         []*fileScope
+
+	// The line where the block being read started
+	blockLine int
+	blockText string
+	// discard is set when the transaction being read must be left out
+	discard bool
+}
+
+// parseResult is a transaction or a problem found by the parser.
+type parseResult struct {
+	trans *Transaction
+	err   error
+}
 
-	for scanner.Scan() {
-		line = scanner.Text()
+func newParser(ledgerReader io.Reader, config *parseConfig) *parser {
+	p := &parser{
+		scanner: bufio.NewScanner(ledgerReader),
+		journal: &Journal{
+			Prices:      NewPriceDB(),
+			Accounts:    make(map[string]*AccountDeclaration),
+			Commodities: make(CommodityFormats),
+		},
+		config:         config,
+		accountAliases: make(map[string]string),
+		scopes:         []*fileScope{{}},
+	}
+	p.scanner.Split(scanLines(&p.lineSize))
+	return p
+}
 
-		// update filename/line if sentinel comment is found
-		if strings.HasPrefix(line, markerPrefix) {
-			filename, lineCount = parseMarker(line)
+// next returns the next transaction or *ParseError found, or io.EOF once the
+// whole file is read. An error reading the file, or the error of ctx once it
+// is done, is returned as is.
+func (p *parser) next(ctx context.Context) (*Transaction, error) {
+	for len(p.results) == 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !p.scanner.Scan() {
+			if err := p.scanner.Err(); err != nil {
+				return nil, err
+			}
+			if p.trans == nil {
+				return nil, io.EOF
+			}
+			// If the file does not end on empty line, we must attempt to balance last
+			// transaction of the file.
+			p.endBlock()
 			continue
 		}
+		p.parseLine(p.scanner.Text())
+	}
+	result := p.results[0]
+	p.results = p.results[1:]
+	return result.trans, result.err
+}
 
-		// remove heading and tailing space from the line
-		trimmedLine := strings.Trim(line, whitespace)
-		lineCount++
+func (p *parser) emit(trans *Transaction, err error) {
+	p.results = append(p.results, parseResult{trans: trans, err: err})
+}
 
-		// handle comments
-		if commentIdx := strings.Index(trimmedLine, ";"); commentIdx >= 0 {
-			comments = append(comments, trimmedLine[commentIdx:])
-			trimmedLine = trimmedLine[:commentIdx]
-			if len(trimmedLine) == 0 {
-				continue
+// parseError reports a problem with text in the current line
+func (p *parser) parseError(code ErrorCode, text, msg string) {
+	p.emit(nil, newParseError(p.filename, p.lineCount, p.line, code, text, msg))
+}
+
+// blockError reports a problem with the whole block being read
+func (p *parser) blockError(code ErrorCode, msg string) {
+	p.emit(nil, newParseError(p.filename, p.blockLine, p.blockText, code, strings.Trim(p.blockText, whitespace), msg))
+}
+
+// transError reports a problem in a transaction, which strict parsing leaves
+// out
+func (p *parser) transError(err *ParseError) {
+	p.discard = p.config.strict
+	p.emit(nil, err)
+}
+
+// endBlock finishes the transaction, automated or periodic transaction being
+// read.
+func (p *parser) endBlock() {
+	trans := p.trans
+	if p.periodic != nil {
+		// The account changes were read into trans, but belong to the budget
+		if transErr := balanceTransaction(trans); transErr != nil {
+			p.blockError(CodeUnbalanced, "Unable to balance periodic transaction, "+transErr.Error())
+		}
+		p.periodic.AccountChanges = trans.AccountChanges
+		p.journal.PeriodicTransactions = append(p.journal.PeriodicTransactions, p.periodic)
+		p.periodic = nil
+	} else if p.rule != nil {
+		// The account changes were read into trans, but belong to the rule
+		p.rule.AccountChanges = trans.AccountChanges
+		for _, accChange := range p.rule.AccountChanges {
+			if accChange.Balance == nil {
+				p.emit(nil, &ParseError{File: accChange.Position.File, Line: accChange.Position.StartLine, Column: 1, Code: CodeMissingAmount,
+					Text: accChange.Name, Msg: "Automated transaction account change with no amount: " + accChange.Name})
 			}
 		}
+		p.rules = append(p.rules, p.rule)
+		p.journal.AutomatedTransactions = append(p.journal.AutomatedTransactions, p.rule)
+		p.rule = nil
+	} else if !p.discard {
+		transErr := balanceTransaction(trans)
+		if transErr != nil {
+			p.blockError(CodeUnbalanced, "Unable to balance transaction, "+transErr.Error())
+		} else if len(p.rules) > 0 {
+			applyAutomatedTransactions(p.rules, trans)
+			if transErr = balanceTransaction(trans); transErr != nil {
+				p.blockError(CodeUnbalanced, "Unable to balance transaction with automated transactions, "+transErr.Error())
+			}
+		}
+		if transErr == nil || !p.config.strict {
+			trans.Comments = p.comments
+			p.emit(trans, nil)
+		}
+	}
+	p.comments = nil
+	p.trans = nil
+	p.discard = false
+}
+
+// linePosition returns the position of the current line.
+func (p *parser) linePosition() Position {
+	return Position{File: p.filename, StartLine: p.lineCount, EndLine: p.lineCount, Start: p.lineStart, End: p.offset}
+}
+
+// parseLine reads one line of the file.
+func (p *parser) parseLine(line string) {
+	p.line = line
+	commodities := p.journal.Commodities
+	scope := p.scopes[len(p.scopes)-1]
+
+	// update filename/line if sentinel comment is found
+	if strings.HasPrefix(line, markerPrefix) {
+		// A transaction or directive ends with the file it is in
+		if p.trans != nil {
+			p.endBlock()
+		}
+		p.subDirective = nil
+		p.pendingTags = nil
+		p.filename, p.lineCount, p.offset = parseMarker(line)
+		p.scopes = enterFile(p.scopes, p.filename)
+		return
+	}
+
+	// remove heading and tailing space from the line
+	trimmedLine := strings.Trim(line, whitespace)
+	p.lineCount++
+	p.lineStart = p.offset
+	p.offset += p.lineSize
+
+	// Comments and account changes extend the transaction being read
+	if p.trans != nil && len(trimmedLine) > 0 {
+		p.trans.Position.EndLine = p.lineCount
+		p.trans.Position.End = p.offset
+	}
 
+	// handle comments
+	var comment string
+	if commentIdx := strings.Index(trimmedLine, ";"); commentIdx >= 0 {
+		comment = trimmedLine[commentIdx:]
+		p.comments = append(p.comments, comment)
+		trimmedLine = strings.TrimRight(trimmedLine[:commentIdx], whitespace)
 		if len(trimmedLine) == 0 {
-			if trans != nil {
-				transErr := balanceTransaction(trans)
-				if transErr != nil {
-					errorMsg("Unable to balance transaction, " + transErr.Error())
-				}
-				trans.Comments = comments
-				callback(trans, nil)
-				comments = nil
-				trans = nil
-			}
-		} else if trans == nil {
-			lineSplit := strings.SplitN(trimmedLine, " ", 2)
-			if len(lineSplit) != 2 {
-				if errorMsg("Unable to parse payee line: " + line) {
-					return
-				}
-				continue
-			}
-			dateString := lineSplit[0]
-			transDate, dateErr := date.Parse(dateString)
-			if dateErr != nil {
-				errorMsg("Unable to parse date: " + dateString)
-			}
-			payeeString := lineSplit[1]
-			trans = &Transaction{Payee: payeeString, Date: transDate}
+			// Tags in a comment line belong to the account change or
+			// transaction above it, or to the next transaction if there is none
+			switch {
+			case p.trans == nil && p.subDirective != nil:
+				// Comments of an account or commodity directive are not kept
+			case p.trans == nil:
+				p.pendingTags = parseTags(comment, p.pendingTags)
+			case len(p.trans.AccountChanges) > 0:
+				lastChange := &p.trans.AccountChanges[len(p.trans.AccountChanges)-1]
+				lastChange.Tags = parseTags(comment, lastChange.Tags)
+			default:
+				p.trans.Tags = parseTags(comment, p.trans.Tags)
+			}
+			return
+		}
+	}
+
+	// Tags of comment lines only belong to a payee line right below them
+	pendingTags := p.pendingTags
+	p.pendingTags = nil
+
+	// A directive lasts as long as its lines are indented
+	if p.subDirective != nil && (len(trimmedLine) == 0 || !strings.ContainsAny(line[:1], whitespace)) {
+		p.subDirective = nil
+	}
+
+	if p.trans == nil {
+		p.blockLine, p.blockText = p.lineCount, line
+	}
+
+	if len(trimmedLine) == 0 {
+		if p.trans != nil {
+			p.endBlock()
+		}
+	} else if p.subDirective != nil {
+		subSplit := strings.SplitN(trimmedLine, " ", 2)
+		value := ""
+		if len(subSplit) == 2 {
+			value = strings.Trim(subSplit[1], whitespace)
+		}
+		if subErr := p.subDirective(subSplit[0], value); subErr != nil {
+			p.parseError(CodeInvalidDirective, value, subErr.Error())
+		}
+	} else if p.trans == nil && isDirective(trimmedLine, "alias") {
+		alias, aliasErr := ParseAlias(trimmedLine[len("alias"):])
+		if aliasErr != nil {
+			p.parseError(CodeInvalidDirective, trimmedLine, aliasErr.Error())
+			return
+		}
+		p.aliases = append(p.aliases, alias)
+	} else if p.trans == nil && trimmedLine == "end aliases" {
+		p.aliases = nil
+	} else if p.trans == nil && isDirective(trimmedLine, "apply account") {
+		prefix := strings.Trim(trimmedLine[len("apply account"):], whitespace+":")
+		scope.accountPrefixes = append(scope.accountPrefixes, prefix)
+	} else if p.trans == nil && (trimmedLine == "end apply account" || trimmedLine == "end apply") {
+		if len(scope.accountPrefixes) == 0 {
+			p.parseError(CodeInvalidDirective, trimmedLine, "No apply account to end")
+			return
+		}
+		scope.accountPrefixes = scope.accountPrefixes[:len(scope.accountPrefixes)-1]
+	} else if p.trans == nil && (isDirective(trimmedLine, "year") || isDirective(trimmedLine, "Y")) {
+		yearString := strings.Join(strings.Fields(trimmedLine)[1:], " ")
+		year, yearErr := strconv.Atoi(yearString)
+		if yearErr != nil || year <= 0 {
+			p.parseError(CodeInvalidDirective, yearString, "Unable to parse year: "+yearString)
+			return
+		}
+		scope.year = year
+	} else if p.trans == nil && isDirective(trimmedLine, "account") {
+		name := scope.accountName(strings.Trim(trimmedLine[len("account"):], whitespace))
+		declaration, ok := p.journal.Accounts[name]
+		if !ok {
+			declaration = &AccountDeclaration{Name: name}
+			p.journal.Accounts[name] = declaration
+		}
+		p.subDirective = func(name, value string) error {
+			switch name {
+			case "note":
+				declaration.Note = value
+			case "alias":
+				declaration.Aliases = append(declaration.Aliases, value)
+				p.accountAliases[value] = declaration.Name
+			case "type":
+				declaration.Type = value
+			}
+			return nil
+		}
+	} else if p.trans == nil && isDirective(trimmedLine, "commodity") {
+		arg := strings.Trim(trimmedLine[len("commodity"):], whitespace)
+		var format *CommodityFormat
+		if strings.ContainsAny(arg, "0123456789") {
+			var formatErr error
+			if format, formatErr = parseCommodityFormat(arg); formatErr != nil {
+				p.parseError(CodeInvalidDirective, arg, formatErr.Error())
+				return
+			}
+		} else if symbol, rest := lexCommodity(arg); symbol != "" && len(rest) == 0 {
+			format = defaultCommodityFormat(symbol)
 		} else {
-			var accChange Account
-			lineSplit := accountToAmountSpace.Split(trimmedLine, -1)
-			var nonEmptyWords []string
-			for _, word := range lineSplit {
-				if len(word) > 0 {
-					nonEmptyWords = append(nonEmptyWords, word)
-				}
-			}
-			lastIndex := len(nonEmptyWords) - 1
-			balErr, rationalNum := getBalance(strings.Trim(nonEmptyWords[lastIndex], whitespace))
-			if !balErr {
-				// Assuming no balance and whole line is account name
-				accChange.Name = strings.Join(nonEmptyWords, " ")
+			p.parseError(CodeInvalidDirective, trimmedLine, "Unable to parse commodity directive: "+line)
+			return
+		}
+		commodities[format.Symbol] = format
+		p.subDirective = func(name, value string) error {
+			if name != "format" {
+				return nil
+			}
+			sample, formatErr := parseCommodityFormat(value)
+			if formatErr != nil {
+				return formatErr
+			}
+			if sample.Symbol != format.Symbol {
+				return fmt.Errorf("Commodity format %s is not for %s", value, format.Symbol)
+			}
+			*format = *sample
+			return nil
+		}
+	} else if p.trans == nil && strings.HasPrefix(trimmedLine, "=") {
+		query, queryErr := ParseQuery(trimmedLine[1:])
+		if queryErr != nil {
+			p.parseError(CodeInvalidDirective, trimmedLine, "Unable to parse automated transaction: "+queryErr.Error())
+			// Read its account changes anyway so they are not taken for a transaction
+			query = &Query{text: trimmedLine[1:], root: orQuery{}}
+		}
+		p.rule = &AutomatedTransaction{Query: query}
+		p.trans = &Transaction{}
+	} else if p.trans == nil && strings.HasPrefix(trimmedLine, "~") {
+		var periodErr error
+		p.periodic, periodErr = parsePeriodicTransaction(trimmedLine[1:])
+		if periodErr != nil {
+			p.parseError(CodeInvalidDirective, trimmedLine, "Unable to parse periodic transaction: "+periodErr.Error())
+			// Read its account changes anyway so they are not taken for a transaction
+			p.periodic = &PeriodicTransaction{Period: PeriodMonth}
+		}
+		p.trans = &Transaction{}
+	} else if p.trans == nil && isDirective(trimmedLine, "P") {
+		price, priceErr := parsePrice(trimmedLine[1:], commodities)
+		if priceErr != nil {
+			p.parseError(CodeInvalidDirective, trimmedLine, priceErr.Error())
+			return
+		}
+		p.journal.Prices.Add(price)
+	} else if p.trans == nil {
+		lineSplit := strings.SplitN(trimmedLine, " ", 2)
+		if len(lineSplit) != 2 {
+			p.transError(newParseError(p.filename, p.lineCount, line, CodeInvalidPayee, trimmedLine, "Unable to parse payee line: "+line))
+			if p.config.strict {
+				// Read its account changes so they are not taken for payee lines
+				p.trans = &Transaction{}
+			}
+			return
+		}
+		dateString := lineSplit[0]
+		transDate, dateErr := date.Parse(dateString)
+		if dateErr != nil {
+			p.transError(newParseError(p.filename, p.lineCount, line, CodeInvalidDate, dateString, "Unable to parse date: "+dateString))
+		} else if transDate.Year() == 0 && scope.year != 0 {
+			// A date such as 03/15 is in the year given by the year directive
+			transDate = time.Date(scope.year, transDate.Month(), transDate.Day(), 0, 0, 0, 0, transDate.Location())
+		} else if transDate.Year() == 0 && p.config.strict {
+			p.transError(newParseError(p.filename, p.lineCount, line, CodeInvalidDate, dateString, "Date with no year and no year directive: "+dateString))
+		}
+		trans := &Transaction{Date: transDate, Position: p.linePosition()}
+		payeeString := lineSplit[1]
+		trans.State, payeeString = parseState(payeeString)
+		if strings.HasPrefix(payeeString, "(") {
+			if codeEnd := strings.Index(payeeString, ")"); codeEnd > 0 {
+				trans.Code = payeeString[1:codeEnd]
+				payeeString = strings.TrimLeft(payeeString[codeEnd+1:], whitespace)
+			}
+		}
+		trans.Payee = payeeString
+		trans.Tags = parseTags(comment, pendingTags)
+		p.trans = trans
+	} else {
+		accChange := Account{Position: p.linePosition()}
+		accChange.State, trimmedLine = parseState(trimmedLine)
+		if len(trimmedLine) == 0 {
+			// A state flag alone names no account
+			p.transError(newParseError(p.filename, p.lineCount, line, CodeMissingAccount, strings.Trim(line, whitespace), "Account change with no account: "+line))
+			return
+		}
+		if assertIdx := assertionIndex(trimmedLine); assertIdx >= 0 {
+			assertion, assertErr := parseAmount(trimmedLine[assertIdx+1:], commodities)
+			if assertErr != nil {
+				p.transError(newParseError(p.filename, p.lineCount, line, CodeInvalidAssertion, trimmedLine[assertIdx:], "Unable to parse balance assertion: "+line))
 			} else {
-				accChange.Name = strings.Join(nonEmptyWords[:lastIndex], " ")
-				accChange.Balance = rationalNum
+				accChange.Assertion = &assertion
+			}
+			trimmedLine = strings.TrimRight(trimmedLine[:assertIdx], whitespace)
+			if len(trimmedLine) == 0 {
+				p.transError(newParseError(p.filename, p.lineCount, line, CodeInvalidAssertion, strings.Trim(line, whitespace), "Balance assertion with no account: "+line))
+				return
+			}
+		}
+		lineSplit := accountToAmountSpace.Split(trimmedLine, -1)
+		var nonEmptyWords []string
+		for _, word := range lineSplit {
+			if len(word) > 0 {
+				nonEmptyWords = append(nonEmptyWords, word)
 			}
-			trans.AccountChanges = append(trans.AccountChanges, accChange)
 		}
+		lastIndex := len(nonEmptyWords) - 1
+		amountWord := nonEmptyWords[lastIndex]
+		amt, cost, lot, amtErr := parsePostingAmount(amountWord, commodities)
+		_, isExpr := amtErr.(*exprError)
+		if isExpr && lastIndex > 0 {
+			// An expression or a cost was meant as the amount, so tell where it is wrong
+			exprErr := shiftColumn(amtErr, strings.Index(line, trimmedLine)+strings.LastIndex(trimmedLine, amountWord)).(*exprError)
+			p.transError(&ParseError{File: p.filename, Line: p.lineCount, Column: exprErr.column, Code: CodeInvalidAmount,
+				Text: amountWord, Msg: "Unable to parse amount: " + exprErr.msg})
+			accChange.Name = strings.Join(nonEmptyWords[:lastIndex], " ")
+		} else if p.config.strict && amtErr != nil && lastIndex > 0 {
+			// Account names have no double spaces, so this was meant as the amount
+			p.transError(newParseError(p.filename, p.lineCount, line, CodeInvalidAmount, amountWord, "Unable to parse amount: "+amtErr.Error()))
+			accChange.Name = strings.Join(nonEmptyWords[:lastIndex], " ")
+		} else if p.config.strict && lastIndex == 0 && trailingAmount(amountWord, commodities) != "" {
+			p.transError(newParseError(p.filename, p.lineCount, line, CodeInvalidAmount, trailingAmount(amountWord, commodities),
+				"Amount must be separated from the account name by two spaces or a tab: "+amountWord))
+			accChange.Name = amountWord
+		} else if amtErr != nil || lastIndex == 0 {
+			// Assuming no balance and whole line is account name
+			accChange.Name = strings.Join(nonEmptyWords, " ")
+		} else {
+			accChange.Name = strings.Join(nonEmptyWords[:lastIndex], " ")
+			accChange.Balance = NewBalance(amt.Commodity, amt.Quantity)
+			accChange.Cost = cost
+			accChange.Lot = lot
+		}
+		accChange.Tags = parseTags(comment, nil)
+		accChange.Type, accChange.Name = parsePostingType(accChange.Name)
+		for _, alias := range p.aliases {
+			accChange.Name = alias.Rename(accChange.Name)
+		}
+		for _, alias := range p.config.aliases {
+			accChange.Name = alias.Rename(accChange.Name)
+		}
+		if name, ok := p.accountAliases[accChange.Name]; ok {
+			// Declared accounts are already under the applied accounts
+			accChange.Name = name
+		} else {
+			accChange.Name = scope.accountName(accChange.Name)
+		}
+		p.trans.AccountChanges = append(p.trans.AccountChanges, accChange)
 	}
-	// If the file does not end on empty line, we must attempt to balance last
-	// transaction of the file.
-	if trans != nil {
-		transErr := balanceTransaction(trans)
-		if transErr != nil {
-			errorMsg("Unable to balance transaction, " + transErr.Error())
+}
+
+// assertionIndex returns the index of the "=" starting the balance assertion
+// of an account change line, or -1 if there is none. Account names may hold
+// "=", so it is only looked for after the two spaces or tab ending the name.
+func assertionIndex(s string) int {
+	if strings.HasPrefix(s, "=") {
+		return 0
+	}
+	loc := accountToAmountSpace.FindStringIndex(s)
+	if loc == nil {
+		return -1
+	}
+	if idx := strings.Index(s[loc[1]:], "="); idx >= 0 {
+		return loc[1] + idx
+	}
+	return -1
+}
+
+// trailingAmount returns the end of an account name that looks like an amount
+// meant to follow it, such as "$10" or "10 USD", or "" if there is none. A
+// name ending with a word made only of digits, such as a year, is left alone.
+func trailingAmount(name string, formats CommodityFormats) string {
+	words := strings.Split(name, " ")
+	if strings.Trim(words[len(words)-1], "0123456789") == "" {
+		return ""
+	}
+	for n := 2; n >= 1; n-- {
+		if len(words) <= n {
+			continue
+		}
+		text := strings.Join(words[len(words)-n:], " ")
+		if _, _, _, err := parsePostingAmount(text, formats); err == nil {
+			return text
 		}
-		trans.Comments = comments
-		callback(trans, nil)
 	}
+	return ""
 }
 
-func getBalance(balance string) (bool, *big.Rat) {
-	rationalNum := new(big.Rat)
-	if strings.Contains(balance, "(") {
-		rationalNum.SetFloat64(calc.Solve(balance))
-		return true, rationalNum
+// fileScope holds the state of the "apply account" and "year" directives,
+// which last until the end of the file they are in. An included file starts
+// with the state of the file including it.
+type fileScope struct {
+	filename        string
+	accountPrefixes []string
+	year            int
+}
+
+// enterFile returns scopes with the scope of filename on top, given the name
+// of the file an include marker switches to. Returning to an including file
+// ends the scopes of the files it included.
+func enterFile(scopes []*fileScope, filename string) []*fileScope {
+	for i := len(scopes) - 1; i >= 0; i-- {
+		if scopes[i].filename == filename {
+			return scopes[:i+1]
+		}
+	}
+	parent := scopes[len(scopes)-1]
+	return append(scopes, &fileScope{
+		filename:        filename,
+		accountPrefixes: append([]string(nil), parent.accountPrefixes...),
+		year:            parent.year,
+	})
+}
+
+// accountName returns name under the accounts applied with "apply account".
+func (scope *fileScope) accountName(name string) string {
+	if len(scope.accountPrefixes) == 0 {
+		return name
+	}
+	return strings.Join(scope.accountPrefixes, ":") + ":" + name
+}
+
+var (
+	metadataComment = regexp.MustCompile(`^([^\s:]+):(?:\s+(.*))?$`)
+	tagsComment     = regexp.MustCompile(`(?:^|\s):((?:[^\s:]+:)+)`)
+)
+
+// parseTags adds the metadata in a comment to tags and returns tags, which is
+// allocated if needed. A comment such as "; Buyer: Pedro" sets the key Buyer to
+// "Pedro" while "; :tag1:tag2:" sets the keys tag1 and tag2 to "".
+func parseTags(comment string, tags map[string]string) map[string]string {
+	text := strings.Trim(strings.TrimLeft(comment, ";"), whitespace)
+	if len(text) == 0 {
+		return tags
+	}
+
+	if match := metadataComment.FindStringSubmatch(text); match != nil {
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+		tags[match[1]] = strings.Trim(match[2], whitespace)
+		return tags
+	}
+
+	for _, match := range tagsComment.FindAllStringSubmatch(text, -1) {
+		for _, tag := range strings.Split(strings.Trim(match[1], ":"), ":") {
+			if tags == nil {
+				tags = make(map[string]string)
+			}
+			tags[tag] = ""
+		}
+	}
+	return tags
+}
+
+// parsePostingType tells a virtual account name, written "(Account)", or a
+// balanced virtual one, written "[Account]", from a real one and returns its
+// type along with the bare account name.
+func parsePostingType(name string) (PostingType, string) {
+	if len(name) > 2 {
+		switch {
+		case name[0] == '(' && name[len(name)-1] == ')':
+			return VirtualPosting, name[1 : len(name)-1]
+		case name[0] == '[' && name[len(name)-1] == ']':
+			return BalancedVirtualPosting, name[1 : len(name)-1]
+		}
 	}
-	_, isValid := rationalNum.SetString(balance)
-	return isValid, rationalNum
+	return RealPosting, name
+}
+
+// parseState reads a "*" or "!" state flag from the start of s and returns
+// the state along with the rest of s.
+func parseState(s string) (TransactionState, string) {
+	s = strings.TrimLeft(s, whitespace)
+	switch {
+	case strings.HasPrefix(s, "*"):
+		return Cleared, strings.TrimLeft(s[1:], whitespace)
+	case strings.HasPrefix(s, "!"):
+		return Pending, strings.TrimLeft(s[1:], whitespace)
+	}
+	return Uncleared, s
+}
+
+// isDirective reports whether line starts with the directive name followed by
+// whitespace.
+func isDirective(line, name string) bool {
+	return len(line) > len(name) && strings.HasPrefix(line, name) &&
+		strings.ContainsRune(whitespace, rune(line[len(name)]))
 }
 
 // Takes a transaction and balances it. This is mainly to fill in the empty part
-// with the remaining balance.
+// with the remaining balance. Each commodity must balance on its own, so the
+// empty part may end up holding several commodities. Account changes with a
+// cost count as their cost, which is what lets a purchase or a currency
+// exchange balance. Account changes naming a lot but no cost count as the lot
+// price. Virtual account changes are not balanced, while balanced virtual
+// ones must balance among themselves.
+//
+// Transactions with a balance assignment are left alone, they are balanced by
+// ApplyBalanceAssertions once the assigned amount is known.
 func balanceTransaction(input *Transaction) error {
-	balance := new(big.Rat)
+	if hasBalanceAssignment(input) {
+		return nil
+	}
+	for _, postingType := range []PostingType{RealPosting, BalancedVirtualPosting} {
+		if err := balancePostings(input, postingType); err != nil {
+			return err
+		}
+	}
+	for _, accChange := range input.AccountChanges {
+		if accChange.Type == VirtualPosting && accChange.Balance == nil {
+			return fmt.Errorf("virtual account change with no amount: %s", accChange.Name)
+		}
+	}
+	return nil
+}
+
+// balancePostings balances the account changes of input that are of the given
+// type among themselves. Real changes and balanced virtual changes each have to
+// balance on their own.
+func balancePostings(input *Transaction, postingType PostingType) error {
+	balance := make(Balance)
 	var emptyFound bool
 	var emptyAccIndex int
 	for accIndex, accChange := range input.AccountChanges {
+		if accChange.Type != postingType {
+			continue
+		}
 		if accChange.Balance == nil {
 			if emptyFound {
 				return fmt.Errorf("more than one account change empty")
@@ -181,16 +739,30 @@ func balanceTransaction(input *Transaction) error {
 			emptyAccIndex = accIndex
 			emptyFound = true
 		} else {
-			balance = balance.Add(balance, accChange.Balance)
+			balance.Add(accChange.weight())
 		}
 	}
-	if balance.Sign() != 0 {
+	if !balance.IsZero() {
 		if !emptyFound {
 			return fmt.Errorf("no empty account change to place extra balance")
 		}
 	}
 	if emptyFound {
-		input.AccountChanges[emptyAccIndex].Balance = balance.Neg(balance)
+		input.AccountChanges[emptyAccIndex].Balance = balance.Neg()
 	}
 	return nil
 }
+
+// weight returns the value an account change contributes when balancing its
+// transaction.
+func (a Account) weight() Balance {
+	if a.Cost != nil {
+		return NewBalance(a.Cost.Commodity, a.Cost.Quantity)
+	}
+	if a.Lot != nil && len(a.Balance) == 1 {
+		for _, quantity := range a.Balance {
+			return NewBalance(a.Lot.Price.Commodity, new(big.Rat).Mul(a.Lot.Price.Quantity, quantity))
+		}
+	}
+	return a.Balance
+}
@@ -26,12 +26,12 @@ var testCases = []testCase{
 				Date:  time.Unix(0, 0).UTC(),
 				AccountChanges: []Account{
 					Account{
-						"Expense/test",
-						big.NewRat(369.0, 1),
+						Name:    "Expense/test",
+						Balance: NewBalance("", big.NewRat(369.0, 1)),
 					},
 					Account{
-						"Assets",
-						big.NewRat(-369.0, 1),
+						Name:    "Assets",
+						Balance: NewBalance("", big.NewRat(-369.0, 1)),
 					},
 				},
 			},
@@ -58,24 +58,24 @@ var testCases = []testCase{
 				Date:  time.Unix(0, 0).UTC(),
 				AccountChanges: []Account{
 					Account{
-						"Expense:Cars R Us",
-						big.NewRat(-388.0, 1),
+						Name:    "Expense:Cars R Us",
+						Balance: NewBalance("", big.NewRat(-388.0, 1)),
 					},
 					Account{
-						"Expense:Cars",
-						big.NewRat(358.0, 1),
+						Name:    "Expense:Cars",
+						Balance: NewBalance("", big.NewRat(358.0, 1)),
 					},
 					Account{
-						"Expense:Cranks",
-						big.NewRat(10.0, 1),
+						Name:    "Expense:Cranks",
+						Balance: NewBalance("", big.NewRat(10.0, 1)),
 					},
 					Account{
-						"Expense:Cranks Unlimited",
-						big.NewRat(10.0, 1),
+						Name:    "Expense:Cranks Unlimited",
+						Balance: NewBalance("", big.NewRat(10.0, 1)),
 					},
 					Account{
-						"Expense:Cranks United",
-						big.NewRat(10.0, 1),
+						Name:    "Expense:Cranks United",
+						Balance: NewBalance("", big.NewRat(10.0, 1)),
 					},
 				},
 				Comments: []string{
@@ -88,12 +88,12 @@ var testCases = []testCase{
 				Date:  time.Unix(0, 0).UTC().AddDate(0, 0, 1),
 				AccountChanges: []Account{
 					Account{
-						"Expense:test",
-						big.NewRat(369.0, 1),
+						Name:    "Expense:test",
+						Balance: NewBalance("", big.NewRat(369.0, 1)),
 					},
 					Account{
-						"Assets",
-						big.NewRat(-369.0, 1),
+						Name:    "Assets",
+						Balance: NewBalance("", big.NewRat(-369.0, 1)),
 					},
 				},
 			},
@@ -112,16 +112,16 @@ var testCases = []testCase{
 				Date:  time.Unix(0, 0).UTC(),
 				AccountChanges: []Account{
 					Account{
-						"Expense/another",
-						big.NewRat(5.0, 1),
+						Name:    "Expense/another",
+						Balance: NewBalance("", big.NewRat(5.0, 1)),
 					},
 					Account{
-						"Expense/test",
-						big.NewRat(123.0, 1),
+						Name:    "Expense/test",
+						Balance: NewBalance("", big.NewRat(123.0, 1)),
 					},
 					Account{
-						"Assets",
-						big.NewRat(-128.0, 1),
+						Name:    "Assets",
+						Balance: NewBalance("", big.NewRat(-128.0, 1)),
 					},
 				},
 			},
@@ -140,12 +140,12 @@ var testCases = []testCase{
 				Date:  time.Unix(0, 0).UTC(),
 				AccountChanges: []Account{
 					Account{
-						"Expense/test",
-						big.NewRat(123.0, 1),
+						Name:    "Expense/test",
+						Balance: NewBalance("", big.NewRat(123.0, 1)),
 					},
 					Account{
-						"Assets",
-						big.NewRat(-123.0, 1),
+						Name:    "Assets",
+						Balance: NewBalance("", big.NewRat(-123.0, 1)),
 					},
 				},
 				Comments: []string{
@@ -168,16 +168,16 @@ var testCases = []testCase{
 				Date:  time.Unix(0, 0).UTC(),
 				AccountChanges: []Account{
 					Account{
-						"Expense/test",
-						big.NewRat(58, 1),
+						Name:    "Expense/test",
+						Balance: NewBalance("", big.NewRat(58, 1)),
 					},
 					Account{
-						"Assets",
-						big.NewRat(-58, 1),
+						Name:    "Assets",
+						Balance: NewBalance("", big.NewRat(-58, 1)),
 					},
 					Account{
-						"Expense/unbalanced",
-						big.NewRat(0, 1),
+						Name:    "Expense/unbalanced",
+						Balance: NewBalance("", big.NewRat(0, 1)),
 					},
 				},
 				Comments: []string{
@@ -202,20 +202,20 @@ var testCases = []testCase{
 				Date:  time.Unix(0, 0).UTC(),
 				AccountChanges: []Account{
 					Account{
-						"Expense/test",
-						big.NewRat(58, 1),
+						Name:    "Expense/test",
+						Balance: NewBalance("", big.NewRat(58, 1)),
 					},
 					Account{
-						"Assets",
-						big.NewRat(-58, 1),
+						Name:    "Assets",
+						Balance: NewBalance("", big.NewRat(-58, 1)),
 					},
 					Account{
-						"Expense/test",
-						big.NewRat(158, 1),
+						Name:    "Expense/test",
+						Balance: NewBalance("", big.NewRat(158, 1)),
 					},
 					Account{
-						"Assets",
-						big.NewRat(-158, 1),
+						Name:    "Assets",
+						Balance: NewBalance("", big.NewRat(-158, 1)),
 					},
 				},
 				Comments: []string{
@@ -225,6 +225,282 @@ var testCases = []testCase{
 		},
 		nil,
 	},
+	testCase{
+		`1970/01/01 Payee
+	Assets:Wallet  $ 12.50
+	Assets:Checking    -40.00 BRL
+	Assets:Broker	10 "VWRA"
+	Assets:Cash  -$2.50
+	Equity
+`,
+		[]*Transaction{
+			&Transaction{
+				Payee: "Payee",
+				Date:  time.Unix(0, 0).UTC(),
+				AccountChanges: []Account{
+					Account{
+						Name:    "Assets:Wallet",
+						Balance: NewBalance("$", big.NewRat(25, 2)),
+					},
+					Account{
+						Name:    "Assets:Checking",
+						Balance: NewBalance("BRL", big.NewRat(-40, 1)),
+					},
+					Account{
+						Name:    "Assets:Broker",
+						Balance: NewBalance("VWRA", big.NewRat(10, 1)),
+					},
+					Account{
+						Name:    "Assets:Cash",
+						Balance: NewBalance("$", big.NewRat(-5, 2)),
+					},
+					Account{
+						Name: "Equity",
+						Balance: Balance{
+							"$":    big.NewRat(-10, 1),
+							"BRL":  big.NewRat(40, 1),
+							"VWRA": big.NewRat(-10, 1),
+						},
+					},
+				},
+			},
+		},
+		nil,
+	},
+	testCase{
+		`1970/01/01 Buy shares
+	Assets:Broker  10 AAPL @ $150.00
+	Assets:Cash
+
+1970/01/02 Exchange
+	Assets:USD  -500 USD @@ 2600 BRL
+	Assets:BRL  2600 BRL
+`,
+		[]*Transaction{
+			&Transaction{
+				Payee: "Buy shares",
+				Date:  time.Unix(0, 0).UTC(),
+				AccountChanges: []Account{
+					Account{
+						Name:    "Assets:Broker",
+						Balance: NewBalance("AAPL", big.NewRat(10, 1)),
+						Cost:    &Amount{big.NewRat(1500, 1), "$"},
+					},
+					Account{
+						Name:    "Assets:Cash",
+						Balance: NewBalance("$", big.NewRat(-1500, 1)),
+					},
+				},
+			},
+			&Transaction{
+				Payee: "Exchange",
+				Date:  time.Unix(0, 0).UTC().AddDate(0, 0, 1),
+				AccountChanges: []Account{
+					Account{
+						Name:    "Assets:USD",
+						Balance: NewBalance("USD", big.NewRat(-500, 1)),
+						Cost:    &Amount{big.NewRat(-2600, 1), "BRL"},
+					},
+					Account{
+						Name:    "Assets:BRL",
+						Balance: NewBalance("BRL", big.NewRat(2600, 1)),
+					},
+				},
+			},
+		},
+		nil,
+	},
+	testCase{
+		`1970/01/01 * (4521) Rent
+	! Expenses:Rent  500
+	Assets:Checking
+`,
+		[]*Transaction{
+			&Transaction{
+				Payee: "Rent",
+				Date:  time.Unix(0, 0).UTC(),
+				State: Cleared,
+				Code:  "4521",
+				AccountChanges: []Account{
+					Account{
+						Name:    "Expenses:Rent",
+						Balance: NewBalance("", big.NewRat(500, 1)),
+						State:   Pending,
+					},
+					Account{
+						Name:    "Assets:Checking",
+						Balance: NewBalance("", big.NewRat(-500, 1)),
+					},
+				},
+			},
+		},
+		nil,
+	},
+	testCase{
+		`; UUID: 1234
+1970/01/01 Groceries ; :food:
+	; Buyer: Pedro
+	Expenses:Food  50 ; :shared:weekly:
+	; Note: split with roommate
+	Assets:Cash
+`,
+		[]*Transaction{
+			&Transaction{
+				Payee: "Groceries",
+				Date:  time.Unix(0, 0).UTC(),
+				Tags: map[string]string{
+					"UUID":  "1234",
+					"food":  "",
+					"Buyer": "Pedro",
+				},
+				AccountChanges: []Account{
+					Account{
+						Name:    "Expenses:Food",
+						Balance: NewBalance("", big.NewRat(50, 1)),
+						Tags: map[string]string{
+							"shared": "",
+							"weekly": "",
+							"Note":   "split with roommate",
+						},
+					},
+					Account{
+						Name:    "Assets:Cash",
+						Balance: NewBalance("", big.NewRat(-50, 1)),
+					},
+				},
+				Comments: []string{
+					"; UUID: 1234",
+					"; :food:",
+					"; Buyer: Pedro",
+					"; :shared:weekly:",
+					"; Note: split with roommate",
+				},
+			},
+		},
+		nil,
+	},
+	testCase{
+		`; :household:
+
+P 1970/01/01 VWRA 100
+; :food:
+1970/01/01 Groceries
+	Expenses:Food  50
+	Assets:Cash
+`,
+		[]*Transaction{
+			&Transaction{
+				Payee: "Groceries",
+				Date:  time.Unix(0, 0).UTC(),
+				Tags:  map[string]string{"food": ""},
+				AccountChanges: []Account{
+					Account{
+						Name:    "Expenses:Food",
+						Balance: NewBalance("", big.NewRat(50, 1)),
+					},
+					Account{
+						Name:    "Assets:Cash",
+						Balance: NewBalance("", big.NewRat(-50, 1)),
+					},
+				},
+				Comments: []string{"; :household:", "; :food:"},
+			},
+		},
+		nil,
+	},
+	testCase{
+		`1970/01/01 Groceries
+	Expenses:Food  50
+	Assets:Checking
+	(Budget:Food)  -50
+	[Savings:Goal]  100
+	[Assets:Checking]
+`,
+		[]*Transaction{
+			&Transaction{
+				Payee: "Groceries",
+				Date:  time.Unix(0, 0).UTC(),
+				AccountChanges: []Account{
+					Account{
+						Name:    "Expenses:Food",
+						Balance: NewBalance("", big.NewRat(50, 1)),
+					},
+					Account{
+						Name:    "Assets:Checking",
+						Balance: NewBalance("", big.NewRat(-50, 1)),
+					},
+					Account{
+						Name:    "Budget:Food",
+						Balance: NewBalance("", big.NewRat(-50, 1)),
+						Type:    VirtualPosting,
+					},
+					Account{
+						Name:    "Savings:Goal",
+						Balance: NewBalance("", big.NewRat(100, 1)),
+						Type:    BalancedVirtualPosting,
+					},
+					Account{
+						Name:    "Assets:Checking",
+						Balance: NewBalance("", big.NewRat(-100, 1)),
+						Type:    BalancedVirtualPosting,
+					},
+				},
+			},
+		},
+		nil,
+	},
+	testCase{
+		`= /^Expenses:Food/ and not tag shared
+	(Budget:Food)  -1
+	[Savings:Tithe]  $ 1
+	[Assets:Checking]  $ -1
+
+1970/01/01 Groceries
+	Expenses:Food  50
+	Expenses:Food:Shared  20 ; :shared:
+	Assets:Checking
+`,
+		[]*Transaction{
+			&Transaction{
+				Payee: "Groceries",
+				Date:  time.Unix(0, 0).UTC(),
+				AccountChanges: []Account{
+					Account{
+						Name:    "Expenses:Food",
+						Balance: NewBalance("", big.NewRat(50, 1)),
+					},
+					Account{
+						Name:    "Expenses:Food:Shared",
+						Balance: NewBalance("", big.NewRat(20, 1)),
+						Tags:    map[string]string{"shared": ""},
+					},
+					Account{
+						Name:    "Assets:Checking",
+						Balance: NewBalance("", big.NewRat(-70, 1)),
+					},
+					Account{
+						Name:    "Budget:Food",
+						Balance: NewBalance("", big.NewRat(-50, 1)),
+						Type:    VirtualPosting,
+					},
+					Account{
+						Name:    "Savings:Tithe",
+						Balance: NewBalance("$", big.NewRat(1, 1)),
+						Type:    BalancedVirtualPosting,
+					},
+					Account{
+						Name:    "Assets:Checking",
+						Balance: NewBalance("$", big.NewRat(-1, 1)),
+						Type:    BalancedVirtualPosting,
+					},
+				},
+				Comments: []string{
+					"; :shared:",
+				},
+			},
+		},
+		nil,
+	},
 }
 
 func TestParseLedger(t *testing.T) {
@@ -254,12 +530,12 @@ func BenchmarkParseLedger(b *testing.B) {
 				Date:  time.Unix(0, 0).UTC(),
 				AccountChanges: []Account{
 					Account{
-						"Expense/test",
-						big.NewRat(369.0, 1),
+						Name:    "Expense/test",
+						Balance: NewBalance("", big.NewRat(369.0, 1)),
 					},
 					Account{
-						"Assets",
-						big.NewRat(-369.0, 1),
+						Name:    "Assets",
+						Balance: NewBalance("", big.NewRat(-369.0, 1)),
 					},
 				},
 			},
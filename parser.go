@@ -0,0 +1,116 @@
+package ledger
+
+import (
+	"context"
+	"io"
+)
+
+// Parser reads the transactions of a ledger file one at a time, so that large
+// files can be processed without keeping every transaction in memory.
+//
+// Unlike ParseJournal, a Parser returns transactions in the order they are in
+// the file and does not check balance assertions. The amount of an account
+// change given by a balance assignment, such as "Assets:Checking  = $100", is
+// left nil: ApplyBalanceAssertions sets it once every transaction is known.
+type Parser struct {
+	ctx    context.Context
+	parser *parser
+	err    error
+}
+
+// NewParser returns a Parser reading ledgerReader until ctx is done. No
+// goroutine is started: the file is only read when Next is called.
+func NewParser(ctx context.Context, ledgerReader io.Reader, opts ...ParseOption) *Parser {
+	return &Parser{ctx: ctx, parser: newParser(ledgerReader, newParseConfig(opts))}
+}
+
+// Next returns the next transaction of the file.
+//
+// A problem in the file is returned as a *ParseError, and Next may be called
+// again to go on with the rest of the file. Next returns io.EOF once the
+// whole file is read, or the error of the context once it is done, and then
+// keeps returning that error.
+//
+// Account changes with a balance assignment have a nil Balance, and so does
+// the account change balancing their transaction.
+func (p *Parser) Next() (*Transaction, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	trans, err := p.parser.next(p.ctx)
+	if _, ok := err.(*ParseError); err != nil && !ok {
+		p.err = err
+	}
+	return trans, err
+}
+
+// Journal returns the information declared by the directives read so far,
+// such as market prices and account declarations. Its Transactions are not
+// set.
+func (p *Parser) Journal() *Journal {
+	return p.parser.journal
+}
+
+// ParseLedgerFunc parses a ledger file and passes each transaction, or each
+// *ParseError, to callback in the order they are found. Parsing ends when
+// callback returns true, when ctx is done or at the end of the file.
+//
+// The error of ctx, or of reading the file, is returned. Transactions are
+// passed as Parser.Next returns them, with balance assignments not applied.
+func ParseLedgerFunc(ctx context.Context, ledgerReader io.Reader, callback func(t *Transaction, err error) (stop bool), opts ...ParseOption) error {
+	p := NewParser(ctx, ledgerReader, opts...)
+	for {
+		trans, err := p.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if _, ok := err.(*ParseError); err != nil && !ok {
+			return err
+		}
+		if callback(trans, err) {
+			return nil
+		}
+	}
+}
+
+// ParseLedgerAsyncContext parses a ledger file with a Parser in a goroutine,
+// sending each transaction to c and each *ParseError to e in the order they
+// are found, then nil, or the error of reading the file, to e. Transactions
+// are sent as Parser.Next returns them, with balance assignments not applied.
+//
+// The goroutine ends once that last error is received, or once ctx is done:
+// a caller that stops receiving early cancels ctx, and nothing more is sent.
+func ParseLedgerAsyncContext(ctx context.Context, ledgerReader io.Reader, opts ...ParseOption) (c chan *Transaction, e chan error) {
+	c = make(chan *Transaction)
+	e = make(chan error)
+
+	go func() {
+		p := NewParser(ctx, ledgerReader, opts...)
+		for {
+			trans, err := p.Next()
+			if err == nil {
+				select {
+				case c <- trans:
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			// Reading goes on after a *ParseError only
+			_, more := err.(*ParseError)
+			if err == io.EOF {
+				err = nil
+			}
+			select {
+			case e <- err:
+			case <-ctx.Done():
+				return
+			}
+			if !more {
+				return
+			}
+		}
+	}()
+	return c, e
+}
@@ -0,0 +1,165 @@
+package ledger
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+const parserLedger = `account Assets:Checking
+
+2026/01/07 Restaurant
+	Expenses:Food  $25
+	Assets:Checking
+
+2026/01/05 Grocery Store
+	Expenses:Food  $10
+	Assets:Checking  $5
+
+2026/01/06 Bakery
+	Expenses:Food  $5
+	Assets:Checking
+`
+
+func TestParserNext(t *testing.T) {
+	p := NewParser(context.Background(), bytes.NewBufferString(parserLedger))
+
+	var payees []string
+	var errs []error
+	for {
+		trans, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		payees = append(payees, trans.Payee)
+	}
+
+	// Transactions come in file order, not sorted by date
+	if strings.Join(payees, ",") != "Restaurant,Grocery Store,Bakery" {
+		t.Errorf("unexpected transactions: %v", payees)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("expected one error, got %v", errs)
+	}
+	if parseErr, ok := errs[0].(*ParseError); !ok || parseErr.Line != 7 || parseErr.Code != CodeUnbalanced {
+		t.Errorf("expected an unbalanced transaction on line 7, got %v", errs[0])
+	}
+	if _, ok := p.Journal().Accounts["Assets:Checking"]; !ok {
+		t.Error("expected the account declaration in the journal")
+	}
+	if _, err := p.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF again, got %v", err)
+	}
+}
+
+func TestParserCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := NewParser(ctx, bytes.NewBufferString(parserLedger))
+
+	if trans, err := p.Next(); err != nil || trans.Payee != "Restaurant" {
+		t.Fatalf("expected the first transaction, got %v, %v", trans, err)
+	}
+	cancel()
+	for i := 0; i < 2; i++ {
+		if trans, err := p.Next(); trans != nil || err != context.Canceled {
+			t.Errorf("expected context.Canceled, got %v, %v", trans, err)
+		}
+	}
+}
+
+func TestParseLedgerFuncStop(t *testing.T) {
+	var payees []string
+	err := ParseLedgerFunc(context.Background(), bytes.NewBufferString(parserLedger), func(trans *Transaction, err error) (stop bool) {
+		if err != nil {
+			return true
+		}
+		payees = append(payees, trans.Payee)
+		return false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The callback stopped at the error of the second transaction
+	if len(payees) != 1 || payees[0] != "Restaurant" {
+		t.Errorf("expected only the first transaction, got %v", payees)
+	}
+}
+
+func TestParseLedgerFuncCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	count := 0
+	err := ParseLedgerFunc(ctx, bytes.NewBufferString(parserLedger), func(trans *Transaction, err error) (stop bool) {
+		count++
+		cancel()
+		return false
+	})
+	if err != context.Canceled || count != 1 {
+		t.Errorf("expected context.Canceled after one call, got %v after %d", err, count)
+	}
+}
+
+func TestParserBalanceAssignment(t *testing.T) {
+	p := NewParser(context.Background(), bytes.NewBufferString(`2026/01/05 Reconcile
+	Assets:Checking  = $100
+	Equity:Opening
+`))
+	trans, err := p.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Balance assignments are only applied by ApplyBalanceAssertions
+	if trans.AccountChanges[0].Balance != nil || trans.AccountChanges[1].Balance != nil {
+		t.Errorf("expected nil balances, got %v", trans.AccountChanges)
+	}
+	if errs := ApplyBalanceAssertions([]*Transaction{trans}); len(errs) != 0 || trans.AccountChanges[0].Balance == nil {
+		t.Errorf("expected the assignment to be applied, got %v", errs)
+	}
+}
+
+func TestParseLedgerAsyncContext(t *testing.T) {
+	c, e := ParseLedgerAsyncContext(context.Background(), bytes.NewBufferString(parserLedger))
+
+	var payees []string
+	errCount := 0
+	for done := false; !done; {
+		select {
+		case trans := <-c:
+			payees = append(payees, trans.Payee)
+		case err := <-e:
+			if err == nil {
+				done = true
+				break
+			}
+			errCount++
+		}
+	}
+	if strings.Join(payees, ",") != "Restaurant,Grocery Store,Bakery" || errCount != 1 {
+		t.Errorf("expected three transactions and one error, got %v and %d errors", payees, errCount)
+	}
+}
+
+func TestParseLedgerAsyncContextCanceled(t *testing.T) {
+	goroutines := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	c, _ := ParseLedgerAsyncContext(ctx, bytes.NewBufferString(parserLedger))
+	if trans := <-c; trans.Payee != "Restaurant" {
+		t.Fatalf("expected the first transaction, got %v", trans)
+	}
+
+	// Canceling ends the goroutine although nothing more is received
+	cancel()
+	for i := 0; runtime.NumGoroutine() > goroutines; i++ {
+		if i == 100 {
+			t.Fatal("the parsing goroutine did not end")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
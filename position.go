@@ -0,0 +1,37 @@
+package ledger
+
+import (
+	"bufio"
+	"fmt"
+)
+
+// Position tells where a transaction or account change was read from: the
+// file, its first and last lines, counting from 1, and the byte offsets in
+// that file of its first line and of the end of its last line, line ending
+// included.
+type Position struct {
+	File      string
+	StartLine int
+	EndLine   int
+	Start     int
+	End       int
+}
+
+func (pos Position) String() string {
+	if pos.StartLine == pos.EndLine {
+		return fmt.Sprintf("%s:%d", pos.File, pos.StartLine)
+	}
+	return fmt.Sprintf("%s:%d-%d", pos.File, pos.StartLine, pos.EndLine)
+}
+
+// scanLines splits lines as bufio.ScanLines does, and sets size to the number
+// of bytes the last line took in the file, line ending included.
+func scanLines(size *int) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		advance, token, err = bufio.ScanLines(data, atEOF)
+		if token != nil {
+			*size = advance
+		}
+		return
+	}
+}
@@ -0,0 +1,82 @@
+package ledger
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPositions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	root := "; Household\n" +
+		"include cash.ledger\n" +
+		"\n" +
+		"2026/01/05 Grocery Store\n" +
+		"\tExpenses:Food  $10\n" +
+		"\t; Receipt: 1234\n" +
+		"\tAssets:Checking\n"
+	// The included file has CRLF line endings
+	cash := "2026/01/06 Bakery\r\n" +
+		"\tExpenses:Food  $5\r\n" +
+		"\tAssets:Cash\r\n"
+	rootPath := filepath.Join(dir, "root.ledger")
+	cashPath := filepath.Join(dir, "cash.ledger")
+	if err := ioutil.WriteFile(rootPath, []byte(root), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(cashPath, []byte(cash), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := NewLedgerReader(rootPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	generalLedger, err := ParseLedger(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(generalLedger) != 2 {
+		t.Fatalf("expected 2 transactions, got %d", len(generalLedger))
+	}
+
+	grocery := generalLedger[0].Position
+	if grocery.File != rootPath || grocery.StartLine != 4 || grocery.EndLine != 7 {
+		t.Errorf("unexpected position %s", grocery)
+	}
+	if text := root[grocery.Start:grocery.End]; text != root[len("; Household\ninclude cash.ledger\n\n"):] {
+		t.Errorf("unexpected transaction text %q", text)
+	}
+	checking := generalLedger[0].AccountChanges[1].Position
+	if checking.StartLine != 7 || root[checking.Start:checking.End] != "\tAssets:Checking\n" {
+		t.Errorf("unexpected position %s: %q", checking, root[checking.Start:checking.End])
+	}
+
+	bakery := generalLedger[1].Position
+	if bakery.File != cashPath || bakery.StartLine != 1 || bakery.EndLine != 3 || bakery.Start != 0 || bakery.End != len(cash) {
+		t.Errorf("unexpected position %s, bytes %d-%d", bakery, bakery.Start, bakery.End)
+	}
+	food := generalLedger[1].AccountChanges[0].Position
+	if cash[food.Start:food.End] != "\tExpenses:Food  $5\r\n" {
+		t.Errorf("unexpected account change text %q", cash[food.Start:food.End])
+	}
+}
+
+func TestPositionsWithoutMarkers(t *testing.T) {
+	data := "2026/01/05 Grocery Store\r\n\tExpenses:Food  $10\r\n\tAssets:Checking\r\n\r\n2026/01/06 Bakery\n\tExpenses:Food  $5\n\tAssets:Cash\n"
+	generalLedger, err := ParseLedger(bytes.NewBufferString(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bakery := generalLedger[1].Position
+	if bakery.StartLine != 5 || data[bakery.Start:bakery.End] != "2026/01/06 Bakery\n\tExpenses:Food  $5\n\tAssets:Cash\n" {
+		t.Errorf("unexpected position %s: %q", bakery, data[bakery.Start:bakery.End])
+	}
+}
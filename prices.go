@@ -0,0 +1,211 @@
+package ledger
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	date "github.com/joyt/godate"
+)
+
+// Price is the market value of one unit of Commodity on Date.
+type Price struct {
+	Date      time.Time
+	Commodity string
+	Price     Amount
+}
+
+// PriceDB holds market prices and answers what a commodity is worth in
+// another commodity on a given date.
+type PriceDB struct {
+	// prices of each commodity, sorted by date
+	prices map[string][]Price
+}
+
+// NewPriceDB returns an empty price database.
+func NewPriceDB() *PriceDB {
+	return &PriceDB{prices: make(map[string][]Price)}
+}
+
+// Add records a price. A price added for the same commodity and date as an
+// existing one takes precedence over it.
+func (db *PriceDB) Add(p Price) {
+	list := db.prices[p.Commodity]
+	idx := sort.Search(len(list), func(i int) bool {
+		return list[i].Date.After(p.Date)
+	})
+	list = append(list, Price{})
+	copy(list[idx+1:], list[idx:])
+	list[idx] = p
+	db.prices[p.Commodity] = list
+}
+
+// Prices returns every recorded price sorted by commodity and date.
+func (db *PriceDB) Prices() []Price {
+	commodities := make([]string, 0, len(db.prices))
+	for commodity := range db.prices {
+		commodities = append(commodities, commodity)
+	}
+	sort.Strings(commodities)
+
+	var prices []Price
+	for _, commodity := range commodities {
+		prices = append(prices, db.prices[commodity]...)
+	}
+	return prices
+}
+
+// latest returns the most recent price of commodity quoted in target that is
+// on or before date.
+func (db *PriceDB) latest(commodity, target string, date time.Time) (Price, bool) {
+	list := db.prices[commodity]
+	for i := len(list) - 1; i >= 0; i-- {
+		if !list[i].Date.After(date) && list[i].Price.Commodity == target {
+			return list[i], true
+		}
+	}
+	return Price{}, false
+}
+
+// Rate returns the value of one unit of commodity in target, using the latest
+// price on or before date. A price of target in commodity is used inverted
+// when it is more recent than any direct price. With no price between them,
+// the rate goes through other commodities, taking the fewest conversions, such
+// as AAPL priced in $ and $ priced in R$. A nil PriceDB knows no prices.
+func (db *PriceDB) Rate(commodity, target string, date time.Time) (*big.Rat, bool) {
+	if commodity == target {
+		return big.NewRat(1, 1), true
+	}
+	if db == nil {
+		return nil, false
+	}
+
+	// Breadth first search of the commodities priced in one another, keeping
+	// the rate of each one found in commodity
+	graph := db.graph(date)
+	rates := map[string]*big.Rat{commodity: big.NewRat(1, 1)}
+	queue := []string{commodity}
+	for len(queue) > 0 {
+		from := queue[0]
+		queue = queue[1:]
+		for _, to := range graph[from] {
+			if _, ok := rates[to]; ok {
+				continue
+			}
+			rate, ok := db.pairRate(from, to, date)
+			if !ok {
+				continue
+			}
+			rates[to] = rate.Mul(rate, rates[from])
+			if to == target {
+				return rates[to], true
+			}
+			queue = append(queue, to)
+		}
+	}
+	return nil, false
+}
+
+// graph returns, for each commodity, the commodities it is priced in or that
+// are priced in it on or before date, sorted.
+func (db *PriceDB) graph(date time.Time) map[string][]string {
+	linked := make(map[string]map[string]bool)
+	link := func(a, b string) {
+		if linked[a] == nil {
+			linked[a] = make(map[string]bool)
+		}
+		linked[a][b] = true
+	}
+	for commodity, list := range db.prices {
+		for _, p := range list {
+			if !p.Date.After(date) && p.Price.Commodity != commodity {
+				link(commodity, p.Price.Commodity)
+				link(p.Price.Commodity, commodity)
+			}
+		}
+	}
+
+	graph := make(map[string][]string)
+	for commodity, others := range linked {
+		for other := range others {
+			graph[commodity] = append(graph[commodity], other)
+		}
+		sort.Strings(graph[commodity])
+	}
+	return graph
+}
+
+// pairRate returns the value of one unit of commodity in target from a price
+// of one in the other, preferring the most recent.
+func (db *PriceDB) pairRate(commodity, target string, date time.Time) (*big.Rat, bool) {
+	direct, directOk := db.latest(commodity, target, date)
+	inverse, inverseOk := db.latest(target, commodity, date)
+	if inverseOk && inverse.Price.Quantity.Sign() == 0 {
+		inverseOk = false
+	}
+
+	switch {
+	case directOk && (!inverseOk || !inverse.Date.After(direct.Date)):
+		return new(big.Rat).Set(direct.Price.Quantity), true
+	case inverseOk:
+		return new(big.Rat).Inv(inverse.Price.Quantity), true
+	}
+	return nil, false
+}
+
+// Value returns amt expressed in target on date, or false if no price is
+// known to convert it.
+func (db *PriceDB) Value(amt Amount, target string, date time.Time) (Amount, bool) {
+	rate, ok := db.Rate(amt.Commodity, target, date)
+	if !ok {
+		return Amount{}, false
+	}
+	return Amount{Quantity: rate.Mul(rate, amt.Quantity), Commodity: target}, true
+}
+
+// Convert returns a new Balance with every commodity of bal expressed in
+// target on date. Commodities with no known price are kept as they are.
+func (db *PriceDB) Convert(bal Balance, target string, date time.Time) Balance {
+	converted := make(Balance)
+	for commodity, quantity := range bal {
+		amt := Amount{Quantity: quantity, Commodity: commodity}
+		if value, ok := db.Value(amt, target, date); ok {
+			amt = value
+		}
+		converted.Add(NewBalance(amt.Commodity, amt.Quantity))
+	}
+	return converted
+}
+
+// parsePrice parses a market price directive such as
+// "P 2026/01/05 USD 5.42 BRL". A time of day after the date is ignored.
+func parsePrice(args string, formats CommodityFormats) (Price, error) {
+	var p Price
+	args = strings.Trim(args, whitespace)
+	lineSplit := strings.SplitN(args, " ", 2)
+	if len(lineSplit) != 2 {
+		return p, fmt.Errorf("Unable to parse price directive: P %s", args)
+	}
+	priceDate, dateErr := date.Parse(lineSplit[0])
+	if dateErr != nil {
+		return p, fmt.Errorf("Unable to parse date: %s", lineSplit[0])
+	}
+
+	rest := strings.TrimLeft(lineSplit[1], whitespace)
+	if timeSplit := strings.SplitN(rest, " ", 2); len(timeSplit) == 2 && strings.Contains(timeSplit[0], ":") {
+		rest = timeSplit[1]
+	}
+
+	commodity, rest := lexCommodity(strings.TrimLeft(rest, whitespace))
+	amt, amtErr := parseAmount(rest, formats)
+	if commodity == "" || amtErr != nil {
+		return p, fmt.Errorf("Unable to parse price directive: P %s", args)
+	}
+
+	p.Date = priceDate
+	p.Commodity = commodity
+	p.Price = amt
+	return p, nil
+}
@@ -0,0 +1,106 @@
+package ledger
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestPriceDirectives(t *testing.T) {
+	data := `P 2026/01/05 USD 5.42 BRL
+P 2026/02/05 12:00:00 USD 5.10 BRL
+P 2026/01/20 "VWRA" $120.50
+
+2026/01/10 Payee
+	Assets:USD  100 USD
+	Assets:BRL
+`
+	journal, err := ParseJournal(bytes.NewBufferString(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(journal.Transactions) != 1 {
+		t.Fatalf("expected 1 transaction, got %d", len(journal.Transactions))
+	}
+	if prices := journal.Prices.Prices(); len(prices) != 3 {
+		t.Fatalf("expected 3 prices, got %d", len(prices))
+	}
+
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		commodity, target string
+		date              time.Time
+		ok                bool
+		rate              *big.Rat
+	}{
+		{"USD", "BRL", day(2026, 1, 4), false, nil},
+		{"USD", "BRL", day(2026, 1, 5), true, big.NewRat(542, 100)},
+		{"USD", "BRL", day(2026, 2, 4), true, big.NewRat(542, 100)},
+		{"USD", "BRL", day(2026, 3, 1), true, big.NewRat(510, 100)},
+		{"BRL", "USD", day(2026, 3, 1), true, big.NewRat(100, 510)},
+		{"VWRA", "$", day(2026, 3, 1), true, big.NewRat(241, 2)},
+		{"VWRA", "BRL", day(2026, 3, 1), false, nil},
+		{"BRL", "BRL", day(2026, 3, 1), true, big.NewRat(1, 1)},
+	}
+	for _, tc := range tests {
+		rate, ok := journal.Prices.Rate(tc.commodity, tc.target, tc.date)
+		if ok != tc.ok {
+			t.Errorf("%s in %s on %s: expected ok=%t, got %t", tc.commodity, tc.target, tc.date.Format("2006/01/02"), tc.ok, ok)
+			continue
+		}
+		if ok && rate.Cmp(tc.rate) != 0 {
+			t.Errorf("%s in %s on %s: expected %s, got %s", tc.commodity, tc.target, tc.date.Format("2006/01/02"), tc.rate, rate)
+		}
+	}
+
+	converted := journal.Prices.Convert(journal.Transactions[0].AccountChanges[0].Balance, "BRL", day(2026, 1, 10))
+	if len(converted) != 1 || converted["BRL"].Cmp(big.NewRat(542, 1)) != 0 {
+		t.Errorf("expected 542 BRL, got %v", converted)
+	}
+}
+
+func TestPriceConversionPath(t *testing.T) {
+	data := `P 2026/01/05 AAPL $200
+P 2026/01/05 R$ $0.20
+P 2026/02/01 EUR $1.10
+`
+	journal, err := ParseJournal(bytes.NewBufferString(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		commodity, target string
+		date              time.Time
+		ok                bool
+		rate              *big.Rat
+	}{
+		{"AAPL", "R$", day(2026, 1, 10), true, big.NewRat(1000, 1)},
+		{"R$", "AAPL", day(2026, 1, 10), true, big.NewRat(1, 1000)},
+		{"AAPL", "EUR", day(2026, 1, 10), false, nil},
+		{"AAPL", "EUR", day(2026, 2, 10), true, big.NewRat(2000, 11)},
+		{"EUR", "R$", day(2026, 2, 10), true, big.NewRat(11, 2)},
+	}
+	for _, tc := range tests {
+		rate, ok := journal.Prices.Rate(tc.commodity, tc.target, tc.date)
+		if ok != tc.ok {
+			t.Errorf("%s in %s on %s: expected ok=%t, got %t", tc.commodity, tc.target, tc.date.Format("2006/01/02"), tc.ok, ok)
+			continue
+		}
+		if ok && rate.Cmp(tc.rate) != 0 {
+			t.Errorf("%s in %s on %s: expected %s, got %s", tc.commodity, tc.target, tc.date.Format("2006/01/02"), tc.rate, rate)
+		}
+	}
+
+	bal := NewBalance("AAPL", big.NewRat(2, 1)).Add(NewBalance("$", big.NewRat(10, 1)))
+	converted := journal.Prices.Convert(bal, "R$", day(2026, 1, 10))
+	if len(converted) != 1 || converted["R$"].Cmp(big.NewRat(2050, 1)) != 0 {
+		t.Errorf("expected R$ 2050, got %v", converted)
+	}
+}
@@ -0,0 +1,252 @@
+package ledger
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Query matches account changes of transactions by account name, payee and
+// tags. It is written as a list of terms:
+//
+//	Food, /^Expenses:Food/  account name matches the regular expression
+//	payee Market, @Market   payee matches the regular expression
+//	tag Buyer, %Buyer=Pedro account change or transaction has the tag,
+//	                        with a value matching the regular expression
+//
+// Terms are combined with "or" when nothing is written between them, and may
+// be combined with "and", "or", "not" and parentheses. Regular expressions are
+// case insensitive.
+type Query struct {
+	text string
+	root queryNode
+}
+
+type queryNode interface {
+	match(t *Transaction, a Account) bool
+}
+
+type (
+	accountTerm struct{ re *regexp.Regexp }
+	payeeTerm   struct{ re *regexp.Regexp }
+	tagTerm     struct {
+		key string
+		re  *regexp.Regexp
+	}
+	notQuery struct{ node queryNode }
+	andQuery []queryNode
+	orQuery  []queryNode
+)
+
+func (q accountTerm) match(t *Transaction, a Account) bool { return q.re.MatchString(a.Name) }
+func (q payeeTerm) match(t *Transaction, a Account) bool   { return q.re.MatchString(t.Payee) }
+func (q notQuery) match(t *Transaction, a Account) bool    { return !q.node.match(t, a) }
+
+func (q tagTerm) match(t *Transaction, a Account) bool {
+	value, ok := t.AccountTag(a, q.key)
+	return ok && (q.re == nil || q.re.MatchString(value))
+}
+
+func (q andQuery) match(t *Transaction, a Account) bool {
+	for _, node := range q {
+		if !node.match(t, a) {
+			return false
+		}
+	}
+	return true
+}
+
+func (q orQuery) match(t *Transaction, a Account) bool {
+	for _, node := range q {
+		if node.match(t, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseQuery parses a query such as "/^Expenses:Food/ and not tag shared".
+func ParseQuery(s string) (*Query, error) {
+	tokens, err := tokenizeQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty query")
+	}
+	p := &queryParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in query", p.tokens[p.pos])
+	}
+	return &Query{text: strings.Trim(s, whitespace), root: root}, nil
+}
+
+// Match reports whether the account change a of transaction t matches q.
+func (q *Query) Match(t *Transaction, a Account) bool {
+	return q.root.match(t, a)
+}
+
+func (q *Query) String() string {
+	return q.text
+}
+
+// tokenizeQuery splits a query into words, parentheses and /regex/ tokens.
+func tokenizeQuery(s string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case strings.IndexByte(whitespace, c) >= 0:
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, s[i:i+1])
+			i++
+		case c == '/':
+			end := i + 1
+			for ; end < len(s) && s[end] != '/'; end++ {
+				if s[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated regular expression in query: %s", s[i:])
+			}
+			tokens = append(tokens, s[i:end+1])
+			i = end + 1
+		default:
+			end := i
+			for ; end < len(s) && strings.IndexByte(whitespace+"()", s[end]) < 0; end++ {
+			}
+			tokens = append(tokens, s[i:end])
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+type queryParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *queryParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *queryParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	var nodes orQuery
+	for {
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+
+		switch p.peek() {
+		case "or", "|":
+			p.next()
+		case "", ")":
+			if len(nodes) == 1 {
+				return nodes[0], nil
+			}
+			return nodes, nil
+		}
+	}
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	var nodes andQuery
+	for {
+		node, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+
+		if token := p.peek(); token != "and" && token != "&" {
+			if len(nodes) == 1 {
+				return nodes[0], nil
+			}
+			return nodes, nil
+		}
+		p.next()
+	}
+}
+
+func (p *queryParser) parseNot() (queryNode, error) {
+	switch token := p.next(); token {
+	case "":
+		return nil, fmt.Errorf("unexpected end of query")
+	case "not", "!":
+		node, err := p.parseNot()
+		return notQuery{node}, err
+	case "(":
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing ) in query")
+		}
+		return node, nil
+	case ")", "and", "&", "or", "|":
+		return nil, fmt.Errorf("unexpected %q in query", token)
+	case "payee":
+		return p.parseTerm("@" + p.next())
+	case "tag":
+		return p.parseTerm("%" + p.next())
+	case "account":
+		return p.parseTerm(p.next())
+	default:
+		return p.parseTerm(token)
+	}
+}
+
+// parseTerm parses a single account, payee (@) or tag (%) term.
+func (p *queryParser) parseTerm(token string) (queryNode, error) {
+	switch {
+	case strings.HasPrefix(token, "@"):
+		re, err := queryRegexp(token[1:])
+		return payeeTerm{re}, err
+	case strings.HasPrefix(token, "%"):
+		tagSplit := strings.SplitN(token[1:], "=", 2)
+		if len(tagSplit[0]) == 0 {
+			return nil, fmt.Errorf("missing tag name in query")
+		}
+		term := tagTerm{key: tagSplit[0]}
+		if len(tagSplit) == 2 {
+			var err error
+			term.re, err = queryRegexp(tagSplit[1])
+			if err != nil {
+				return nil, err
+			}
+		}
+		return term, nil
+	}
+	re, err := queryRegexp(token)
+	return accountTerm{re}, err
+}
+
+// queryRegexp compiles a case insensitive regular expression, which may be
+// written between slashes.
+func queryRegexp(s string) (*regexp.Regexp, error) {
+	if len(s) >= 2 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/") {
+		s = s[1 : len(s)-1]
+	}
+	if len(s) == 0 {
+		return nil, fmt.Errorf("missing pattern in query")
+	}
+	return regexp.Compile("(?i)" + s)
+}
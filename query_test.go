@@ -0,0 +1,49 @@
+package ledger
+
+import (
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	trans := &Transaction{
+		Payee: "Supermarket",
+		Tags:  map[string]string{"Buyer": "Pedro"},
+	}
+	food := Account{Name: "Expenses:Food"}
+	shared := Account{Name: "Expenses:Home", Tags: map[string]string{"shared": ""}}
+
+	tests := []struct {
+		query       string
+		food, share bool
+	}{
+		{"Food", true, false},
+		{"/^expenses:/", true, true},
+		{"Food Home", true, true},
+		{"Food or Home", true, true},
+		{"Expenses and not tag shared", true, false},
+		{"payee super", true, true},
+		{"@Market and Home", false, true},
+		{"%Buyer=^Pedro$", true, true},
+		{"tag Buyer=Ana", false, false},
+		{"not (Food or %shared)", false, false},
+	}
+	for _, tc := range tests {
+		q, err := ParseQuery(tc.query)
+		if err != nil {
+			t.Errorf("%q: %s", tc.query, err)
+			continue
+		}
+		if got := q.Match(trans, food); got != tc.food {
+			t.Errorf("%q on %s: expected %t, got %t", tc.query, food.Name, tc.food, got)
+		}
+		if got := q.Match(trans, shared); got != tc.share {
+			t.Errorf("%q on %s: expected %t, got %t", tc.query, shared.Name, tc.share, got)
+		}
+	}
+
+	for _, query := range []string{"", "Food and", "(Food", "/Food", "tag", "Food )"} {
+		if _, err := ParseQuery(query); err == nil {
+			t.Errorf("%q: expected an error", query)
+		}
+	}
+}
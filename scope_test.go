@@ -0,0 +1,62 @@
+package ledger
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestFileScopedDirectives(t *testing.T) {
+	// The markers are what NewLedgerReader writes around an included file
+	data := marker("root.ledger", 0, 0) + `
+year 2026
+apply account Personal
+
+03/01 Salary
+	Assets:Checking  $100.00
+	Income:Salary
+
+` + marker("business.ledger", 0, 0) + `
+apply account Business
+year 2025
+
+12/15 Client
+	Assets:Checking  $50.00
+	Income:Consulting
+` + marker("root.ledger", 8, 113) + `
+end apply account
+
+03/20 Rent
+	Expenses:Rent  $10.00
+	Assets:Checking
+`
+	generalLedger, err := ParseLedger(bytes.NewBufferString(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		date     time.Time
+		accounts []string
+	}{
+		{time.Date(2025, 12, 15, 0, 0, 0, 0, time.UTC), []string{"Personal:Business:Assets:Checking", "Personal:Business:Income:Consulting"}},
+		{time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), []string{"Personal:Assets:Checking", "Personal:Income:Salary"}},
+		{time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC), []string{"Expenses:Rent", "Assets:Checking"}},
+	}
+	if len(generalLedger) != len(expected) {
+		t.Fatalf("expected %d transactions, got %d", len(expected), len(generalLedger))
+	}
+	for i, trans := range generalLedger {
+		if !trans.Date.Equal(expected[i].date) {
+			t.Errorf("transaction %d: expected date %s, got %s", i, expected[i].date.Format("2006/01/02"), trans.Date.Format("2006/01/02"))
+		}
+		for j, accChange := range trans.AccountChanges {
+			if accChange.Name != expected[i].accounts[j] {
+				t.Errorf("transaction %d: expected %s, got %s", i, expected[i].accounts[j], accChange.Name)
+			}
+		}
+	}
+
+	if _, err := ParseLedger(bytes.NewBufferString("end apply account\n")); err == nil {
+		t.Error("expected an error ending an apply account that was not started")
+	}
+}
@@ -0,0 +1,129 @@
+package ledger
+
+import (
+	"bytes"
+	"testing"
+)
+
+const strictLedger = `2026/01/05 Grocery Store
+	Expenses:Food  $10
+	Assets:Checking
+
+2026/13/05 Pay day
+	Income:Salary  $-100
+	Assets:Checking
+
+2026/01/07 Restaurant
+	Expenses:Food $25
+	Assets:Checking
+
+2026/01/08 Book Store
+	Expenses:Books  $1.2.3
+	Assets:Checking
+
+2026/01/09
+	Expenses:Food  $5
+	Assets:Checking
+
+2026/01/10 Bakery
+	Expenses:Food  10 USD
+	Assets:Cash  -10 USD
+`
+
+func TestStrictParsing(t *testing.T) {
+	trans, err := ParseLedger(bytes.NewBufferString(strictLedger), WithStrictParsing())
+	errs, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("expected an ErrorList, got %v", err)
+	}
+
+	expected := []struct {
+		line, column int
+		code         ErrorCode
+	}{
+		{5, 1, CodeInvalidDate},
+		{10, 16, CodeInvalidAmount},
+		{14, 18, CodeInvalidAmount},
+		{17, 1, CodeInvalidPayee},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, exp := range expected {
+		if errs[i].Line != exp.line || errs[i].Column != exp.column || errs[i].Code != exp.code {
+			t.Errorf("error %d: expected line %d, column %d, %s, got %d, %d, %s (%v)",
+				i, exp.line, exp.column, exp.code, errs[i].Line, errs[i].Column, errs[i].Code, errs[i])
+		}
+	}
+
+	if len(trans) != 2 || trans[0].Payee != "Grocery Store" || trans[1].Payee != "Bakery" {
+		t.Fatalf("expected only the valid transactions, got %d", len(trans))
+	}
+	for _, t2 := range trans {
+		if t2.Date.IsZero() || t2.Date.Year() == 0 {
+			t.Errorf("%s: zero date", t2.Payee)
+		}
+	}
+}
+
+func TestStrictParsingDateWithoutYear(t *testing.T) {
+	data := `03/15 Coffee
+	Expenses:Food  $3
+	Assets:Cash
+
+year 2026
+
+03/16 Coffee
+	Expenses:Food  $3
+	Assets:Cash
+`
+	trans, err := ParseLedger(bytes.NewBufferString(data), WithStrictParsing())
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 1 || errs[0].Line != 1 || errs[0].Code != CodeInvalidDate {
+		t.Errorf("expected an invalid date on line 1, got %v", err)
+	}
+	if len(trans) != 1 || trans[0].Date.Year() != 2026 || trans[0].Date.Day() != 16 {
+		t.Fatalf("expected only the transaction dated by the year directive, got %d", len(trans))
+	}
+
+	// Without strict parsing the date is kept as written
+	trans, err = ParseLedger(bytes.NewBufferString(data))
+	if err != nil || len(trans) != 2 {
+		t.Errorf("expected both transactions, got %d, %v", len(trans), err)
+	}
+}
+
+func TestLenientParsing(t *testing.T) {
+	// Without strict parsing the half parsed transactions are returned, and
+	// the account changes after the payee line with no payee are taken for
+	// transactions
+	trans, err := ParseLedger(bytes.NewBufferString(strictLedger))
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	if len(trans) != 6 {
+		t.Fatalf("expected 6 transactions, got %d", len(trans))
+	}
+	if !trans[0].Date.IsZero() {
+		t.Errorf("expected the transaction with an invalid date first, got %s", trans[0].Payee)
+	}
+}
+
+func TestTrailingAmount(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{"Expenses:Food $10", "$10"},
+		{"Expenses:Food 10 USD", "10 USD"},
+		{"Expenses:Food -10.50", "-10.50"},
+		{"Assets:Savings 2024", ""},
+		{"Assets:Bank Account", ""},
+		{"Expenses:Food", ""},
+	}
+	for _, tc := range testCases {
+		if amt := trailingAmount(tc.name, nil); amt != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.expected, amt)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+)
+
+// NodeKind tells what a line, or block of lines, of a ledger file is.
+type NodeKind int
+
+// Node kinds of a SyntaxTree
+const (
+	BlankNode                NodeKind = iota // empty or whitespace only line
+	CommentNode                              // comment line
+	DirectiveNode                            // directive such as account or commodity, with its indented lines
+	TransactionNode                          // payee line, with its account changes and comments
+	AutomatedTransactionNode                 // "=" line, with its account changes and comments
+	PeriodicTransactionNode                  // "~" line, with its account changes and comments
+	PostingNode                              // account change in a transaction
+)
+
+// SyntaxNode is a line of a ledger file. A transaction or a directive is the
+// node of its first line, and its other lines are the Children of that node.
+//
+// Text is the line as written, and Newline its line ending, which is empty on
+// the last line of a file that does not end with one. The other fields are
+// the parts of the line, as written but with the surrounding whitespace
+// removed:
+//
+//	Indent     the whitespace the line starts with
+//	Date       the date of a payee line
+//	Payee      the rest of a payee line: state, code and payee
+//	Keyword    the name of a directive, "=" or "~"
+//	Value      the rest of a directive, "=" or "~" line
+//	Account    the account of an account change, with its state
+//	Amount     the amount of an account change, as written, such as "(123 * 3)"
+//	Assertion  the balance assertion of an account change, from "="
+//	Comment    the comment of the line, from ";"
+type SyntaxNode struct {
+	Kind     NodeKind
+	Text     string
+	Newline  string
+	Children []*SyntaxNode
+
+	Indent    string
+	Date      string
+	Payee     string
+	Keyword   string
+	Value     string
+	Account   string
+	Amount    string
+	Assertion string
+	Comment   string
+}
+
+// SyntaxTree is a lossless syntax tree of a ledger file: writing it gives back
+// the file byte for byte, with its formatting, comments and the amounts as
+// they were written. It is meant for tools that edit ledger files.
+type SyntaxTree struct {
+	Nodes []*SyntaxNode
+}
+
+// directiveKeywords are the directives with a line of their own, which are
+// not taken for payee lines.
+var directiveKeywords = []string{"account", "alias", "apply", "commodity", "end", "include", "P", "year", "Y"}
+
+// ParseSyntax reads a ledger file into a SyntaxTree. Only errors reading the
+// file are returned: lines that ParseLedger would reject are kept as the
+// node they look like. Include directives are not followed.
+func ParseSyntax(ledgerReader io.Reader) (*SyntaxTree, error) {
+	tree := &SyntaxTree{}
+	scanner := bufio.NewScanner(ledgerReader)
+	scanner.Split(scanRawLines)
+
+	// block is the transaction or directive whose lines are being read
+	var block *SyntaxNode
+	for scanner.Scan() {
+		node := newSyntaxNode(scanner.Text())
+		trimmedLine := strings.Trim(node.Text, whitespace)
+		isComment := strings.HasPrefix(trimmedLine, ";")
+
+		switch {
+		case len(trimmedLine) == 0:
+			node.Kind = BlankNode
+			block = nil
+		case block != nil && (block.Kind != DirectiveNode || len(node.Indent) > 0 || isComment):
+			// A transaction lasts until a blank line, and a directive as long
+			// as its lines are indented
+			switch {
+			case isComment:
+				node.Kind = CommentNode
+			case block.Kind == DirectiveNode:
+				node.Kind = DirectiveNode
+				node.Keyword, node.Value = splitKeyword(node.body())
+			default:
+				node.Kind = PostingNode
+				node.parsePosting()
+			}
+			block.Children = append(block.Children, node)
+			continue
+		case isComment:
+			node.Kind = CommentNode
+		default:
+			body := node.body()
+			switch {
+			case strings.HasPrefix(body, "="):
+				node.Kind = AutomatedTransactionNode
+				node.Keyword, node.Value = "=", strings.Trim(body[1:], whitespace)
+			case strings.HasPrefix(body, "~"):
+				node.Kind = PeriodicTransactionNode
+				node.Keyword, node.Value = "~", strings.Trim(body[1:], whitespace)
+			case isDirectiveKeyword(body):
+				node.Kind = DirectiveNode
+				node.Keyword, node.Value = splitKeyword(body)
+			default:
+				node.Kind = TransactionNode
+				node.Date, node.Payee = splitKeyword(body)
+			}
+			block = node
+		}
+		tree.Nodes = append(tree.Nodes, node)
+	}
+	return tree, scanner.Err()
+}
+
+// WriteTo writes the file the tree was read from, with the changes made to
+// the Text and Newline of its nodes.
+func (tree *SyntaxTree) WriteTo(w io.Writer) (n int64, err error) {
+	var buf bytes.Buffer
+	for _, node := range tree.Nodes {
+		node.writeTo(&buf)
+	}
+	return buf.WriteTo(w)
+}
+
+func (tree *SyntaxTree) String() string {
+	var buf bytes.Buffer
+	tree.WriteTo(&buf)
+	return buf.String()
+}
+
+func (node *SyntaxNode) writeTo(buf *bytes.Buffer) {
+	buf.WriteString(node.Text)
+	buf.WriteString(node.Newline)
+	for _, child := range node.Children {
+		child.writeTo(buf)
+	}
+}
+
+// newSyntaxNode returns the node of a line, with its Indent and Comment set.
+func newSyntaxNode(rawLine string) *SyntaxNode {
+	node := &SyntaxNode{Text: rawLine}
+	switch {
+	case strings.HasSuffix(rawLine, "\r\n"):
+		node.Text, node.Newline = rawLine[:len(rawLine)-2], "\r\n"
+	case strings.HasSuffix(rawLine, "\n"):
+		node.Text, node.Newline = rawLine[:len(rawLine)-1], "\n"
+	}
+	rest := strings.TrimLeft(node.Text, whitespace)
+	node.Indent = node.Text[:len(node.Text)-len(rest)]
+	if commentIdx := strings.Index(rest, ";"); commentIdx >= 0 {
+		node.Comment = strings.TrimRight(rest[commentIdx:], whitespace)
+	}
+	return node
+}
+
+// body returns the line without its indentation and comment.
+func (node *SyntaxNode) body() string {
+	body := strings.TrimLeft(node.Text, whitespace)
+	if commentIdx := strings.Index(body, ";"); commentIdx >= 0 {
+		body = body[:commentIdx]
+	}
+	return strings.TrimRight(body, whitespace)
+}
+
+// parsePosting sets the Account, Amount and Assertion of an account change
+// the way ParseLedger reads them. Account names have no double spaces, so the
+// amount starts after the first two spaces or tab.
+func (node *SyntaxNode) parsePosting() {
+	body := node.body()
+	if assertIdx := assertionIndex(body); assertIdx >= 0 {
+		node.Assertion = body[assertIdx:]
+		body = strings.TrimRight(body[:assertIdx], whitespace)
+	}
+	node.Account = body
+	if loc := accountToAmountSpace.FindStringIndex(body); loc != nil {
+		node.Account, node.Amount = body[:loc[0]], body[loc[1]:]
+	}
+}
+
+// splitKeyword splits s at its first whitespace.
+func splitKeyword(s string) (string, string) {
+	if idx := strings.IndexAny(s, whitespace); idx >= 0 {
+		return s[:idx], strings.Trim(s[idx:], whitespace)
+	}
+	return s, ""
+}
+
+func isDirectiveKeyword(s string) bool {
+	for _, keyword := range directiveKeywords {
+		if s == keyword || isDirective(s, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanRawLines splits lines as bufio.ScanLines does, but keeps their line
+// endings.
+func scanRawLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
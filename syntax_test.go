@@ -0,0 +1,141 @@
+package ledger
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+const syntaxLedger = "; Household ledger\n" +
+	"account Assets:Checking\n" +
+	"    note Main account   \n" +
+	"commodity $1,000.00\n" +
+	"\n" +
+	"P 2026/01/01 VWRA $100\n" +
+	"= expr account =~ /Food/\n" +
+	"\t(Budget:Food)  -1\n" +
+	"\n" +
+	"2026/01/05 * (1234) Grocery Store  ; :food:\n" +
+	"  ; Receipt: 42\n" +
+	"\tExpenses:Food  ($10.50 * 3)\n" +
+	"\t! Assets:Checking\t\t$-31.50 = $968.50 ; checked\n" +
+	"   \t\n" +
+	"~ Monthly\r\n" +
+	"    Expenses:Rent    $500\r\n" +
+	"    Assets:Checking\r\n" +
+	"\r\n" +
+	"2026/01/06 Bakery\n" +
+	"\tExpenses:Food  $5\n" +
+	"\tAssets:Checking"
+
+func TestSyntaxRoundTrip(t *testing.T) {
+	files, _ := filepath.Glob(filepath.Join("testdata", "*"))
+	inputs := map[string]string{"syntaxLedger": syntaxLedger}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs[file] = string(data)
+	}
+
+	for name, input := range inputs {
+		tree, err := ParseSyntax(bytes.NewBufferString(input))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var buf bytes.Buffer
+		if _, err := tree.WriteTo(&buf); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if buf.String() != input {
+			t.Errorf("%s: expected\n%q\ngot\n%q", name, input, buf.String())
+		}
+	}
+}
+
+func TestSyntaxNodes(t *testing.T) {
+	tree, err := ParseSyntax(bytes.NewBufferString(syntaxLedger))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kinds []NodeKind
+	for _, node := range tree.Nodes {
+		kinds = append(kinds, node.Kind)
+	}
+	expectedKinds := []NodeKind{CommentNode, DirectiveNode, DirectiveNode, BlankNode, DirectiveNode,
+		AutomatedTransactionNode, BlankNode, TransactionNode, BlankNode, PeriodicTransactionNode, BlankNode, TransactionNode}
+	if len(kinds) != len(expectedKinds) {
+		t.Fatalf("expected kinds %v, got %v", expectedKinds, kinds)
+	}
+	for i := range kinds {
+		if kinds[i] != expectedKinds[i] {
+			t.Fatalf("expected kinds %v, got %v", expectedKinds, kinds)
+		}
+	}
+
+	account := tree.Nodes[1]
+	if account.Keyword != "account" || account.Value != "Assets:Checking" || len(account.Children) != 1 ||
+		account.Children[0].Keyword != "note" || account.Children[0].Value != "Main account" {
+		t.Errorf("unexpected account directive %+v", account)
+	}
+
+	trans := tree.Nodes[7]
+	if trans.Date != "2026/01/05" || trans.Payee != "* (1234) Grocery Store" || trans.Comment != "; :food:" {
+		t.Errorf("unexpected payee line %+v", trans)
+	}
+	if len(trans.Children) != 3 || trans.Children[0].Kind != CommentNode || trans.Children[0].Indent != "  " {
+		t.Fatalf("unexpected transaction lines %+v", trans.Children)
+	}
+	food := trans.Children[1]
+	if food.Kind != PostingNode || food.Account != "Expenses:Food" || food.Amount != "($10.50 * 3)" {
+		t.Errorf("unexpected account change %+v", food)
+	}
+	checking := trans.Children[2]
+	if checking.Account != "! Assets:Checking" || checking.Amount != "$-31.50" ||
+		checking.Assertion != "= $968.50" || checking.Comment != "; checked" {
+		t.Errorf("unexpected account change %+v", checking)
+	}
+
+	periodic := tree.Nodes[9]
+	if periodic.Value != "Monthly" || periodic.Newline != "\r\n" || len(periodic.Children) != 2 ||
+		periodic.Children[0].Indent != "    " || periodic.Children[0].Amount != "$500" {
+		t.Errorf("unexpected periodic transaction %+v", periodic)
+	}
+
+	last := tree.Nodes[11].Children[1]
+	if last.Account != "Assets:Checking" || last.Newline != "" {
+		t.Errorf("unexpected last line %+v", last)
+	}
+}
+
+func TestSyntaxPostingWithEquals(t *testing.T) {
+	tree, err := ParseSyntax(bytes.NewBufferString("2026/01/05 Transfer\n\tExpenses:A=B  50\n\tAssets:Checking  = -50\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	postings := tree.Nodes[0].Children
+	if postings[0].Account != "Expenses:A=B" || postings[0].Amount != "50" || postings[0].Assertion != "" {
+		t.Errorf("unexpected account change %+v", postings[0])
+	}
+	if postings[1].Account != "Assets:Checking" || postings[1].Amount != "" || postings[1].Assertion != "= -50" {
+		t.Errorf("unexpected account change %+v", postings[1])
+	}
+}
+
+func TestSyntaxEdit(t *testing.T) {
+	tree, err := ParseSyntax(bytes.NewBufferString(syntaxLedger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.Nodes[11].Payee = "Corner Bakery"
+	tree.Nodes[11].Text = tree.Nodes[11].Date + " " + tree.Nodes[11].Payee
+
+	expected := syntaxLedger[:len(syntaxLedger)-len("Bakery\n\tExpenses:Food  $5\n\tAssets:Checking")] +
+		"Corner Bakery\n\tExpenses:Food  $5\n\tAssets:Checking"
+	if tree.String() != expected {
+		t.Errorf("expected\n%q\ngot\n%q", expected, tree.String())
+	}
+}
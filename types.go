@@ -1,23 +1,139 @@
 package ledger
 
 import (
-	"math/big"
 	"time"
 )
 
+// TransactionState is the clearing state of a transaction or account change,
+// written as "*" (cleared) or "!" (pending) before the payee or account name.
+type TransactionState int
+
+// Transaction states supported by ledger
+const (
+	Uncleared TransactionState = iota
+	Pending
+	Cleared
+)
+
+func (s TransactionState) String() string {
+	switch s {
+	case Pending:
+		return "pending"
+	case Cleared:
+		return "cleared"
+	}
+	return "uncleared"
+}
+
+// PostingType tells real account changes from virtual ones, which are written
+// with the account name in parentheses or brackets.
+type PostingType int
+
+// Posting types supported by ledger
+const (
+	RealPosting            PostingType = iota
+	VirtualPosting                     // (Account): not balanced
+	BalancedVirtualPosting             // [Account]: balanced with the other balanced virtual changes
+)
+
 // Account holds the name and balance
+//
+// When an account change was given a cost with "@" or "@@", Cost holds the
+// total cost with the same sign as Balance, and the transaction is balanced
+// using the cost instead of the balance. Otherwise a change naming its Lot is
+// balanced using the lot price.
+//
+// State is the state given to the account change itself, if any. Use
+// Transaction.AccountState for the state that applies to it.
+//
+// Type tells whether the account change is real or virtual.
+//
+// Assertion is the balance the account must have in that commodity after the
+// change, written "= 1234.56" after the amount. When the amount is left out,
+// the change is a balance assignment and its amount is computed from the
+// assertion by ApplyBalanceAssertions.
+//
+// Position is the line the account change was read from.
 type Account struct {
-	Name    string
-	Balance *big.Rat
+	Name      string
+	Balance   Balance
+	Cost      *Amount           `json:",omitempty"`
+	Lot       *Lot              `json:",omitempty"`
+	State     TransactionState  `json:",omitempty"`
+	Tags      map[string]string `json:",omitempty"`
+	Type      PostingType       `json:",omitempty"`
+	Assertion *Amount           `json:",omitempty"`
+	Position  Position          `json:"-"`
+}
+
+// Lot identifies a purchase of a commodity by its cost basis per unit and,
+// when known, its acquisition date.
+type Lot struct {
+	Price Amount
+	Date  time.Time
 }
 
 // Transaction is the basis of a ledger. The ledger holds a list of transactions.
 // A Transaction has a Payee, Date (with no time, or to put another way, with
 // hours,minutes,seconds values that probably doesn't make sense), and a list of
 // Account values that hold the value of the transaction for each account.
+// A transaction may also have a clearing State and a Code, such as a check
+// number, written in parentheses before the payee.
+//
+// Tags holds the metadata found in comments, such as "; Buyer: Pedro" or
+// "; :tag1:tag2:" (tags with no value map to ""). Comments before the payee
+// line or directly after it belong to the transaction, those on or after an
+// account line belong to that account change. Comments keeps the raw text of
+// every comment.
+//
+// Forecast is set on transactions generated from periodic transactions.
+//
+// Position spans the lines from the payee line to the last account change or
+// comment of the transaction.
 type Transaction struct {
 	Payee          string
 	Date           time.Time
+	State          TransactionState  `json:",omitempty"`
+	Code           string            `json:",omitempty"`
+	Tags           map[string]string `json:",omitempty"`
 	AccountChanges []Account
 	Comments       []string
+	Forecast       bool     `json:",omitempty"`
+	Position       Position `json:"-"`
+}
+
+// AccountState returns the state that applies to an account change of t: its
+// own state if it was given one, or the state of t otherwise.
+func (t *Transaction) AccountState(a Account) TransactionState {
+	if a.State != Uncleared {
+		return a.State
+	}
+	return t.State
+}
+
+// AccountTag returns the value of the tag key for an account change of t,
+// looking at the tags of the account change first and then at those of t.
+func (t *Transaction) AccountTag(a Account, key string) (string, bool) {
+	if value, ok := a.Tags[key]; ok {
+		return value, true
+	}
+	value, ok := t.Tags[key]
+	return value, ok
+}
+
+// Journal is everything parsed from a ledger file: its transactions along with
+// the information declared by directives, such as market prices.
+//
+// AutomatedTransactions have already been applied to Transactions.
+// PeriodicTransactions define budgets and do not appear in Transactions.
+// Accounts is the chart of accounts declared with "account" directives, by
+// account name. Commodities holds the formats declared with "commodity"
+// directives.
+type Journal struct {
+	Transactions          []*Transaction
+	Prices                *PriceDB
+	AutomatedTransactions []*AutomatedTransaction
+	PeriodicTransactions  []*PeriodicTransaction
+	Accounts              map[string]*AccountDeclaration
+	Commodities           CommodityFormats
 }